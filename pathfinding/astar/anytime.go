@@ -0,0 +1,175 @@
+package astar
+
+import (
+	"container/heap"
+	"math"
+	"time"
+)
+
+/*
+随时搜索（ARA*）
+先用较大的膨胀系数ε快速找到一条路径，再逐步减小ε、复用已有的搜索结果改进路径，
+直到ε为1（最短路径）或者预算用完
+每一轮中被关闭后成本又降低的节点放进不一致列表，下一轮开始时重新放回开放列表
+*/
+
+// 随时搜索参数
+type AnytimeOptions struct {
+	// 初始膨胀系数，默认3
+	Epsilon float64
+	// 每轮递减量，默认0.5
+	Step float64
+	// 时间预算，0表示不限
+	Timeout time.Duration
+	// 扩展节点数预算，0表示不限
+	MaxExpanded int
+	// 每找到更优的路径时回调，bound为当前路径成本与最短路径之比的上界
+	// 返回false时停止搜索
+	OnImprove func(path *Path, bound float64) bool
+}
+
+// 随时搜索，返回预算内找到的最优路径及其次优上界
+// 预算用完时仍未找到路径返回ErrBudget，起止点的处理（SnapRadius、Redirect、连通区域）与FindPath相同
+func (r *AStar) FindPathAnytime(start, end *Node, opts AnytimeOptions) (*Path, float64, error) {
+	r.grid.RLock()
+	defer r.grid.RUnlock()
+	s := r.Acquire()
+	defer r.Release(s)
+	e, err := s.resolve(start, end)
+	if err != nil {
+		return nil, 0, err
+	}
+	return s.findAnytime(e, opts)
+}
+
+func (r *Searcher) findAnytime(e *endpoints, opts AnytimeOptions) (*Path, float64, error) {
+	epsilon, step := opts.Epsilon, opts.Step
+	if epsilon < 1 {
+		epsilon = 3
+	}
+	if step <= 0 {
+		step = 0.5
+	}
+	var deadline time.Time
+	if opts.Timeout > 0 {
+		deadline = time.Now().Add(opts.Timeout)
+	}
+	r.begin(e.start, e.end)
+	// 不一致列表
+	incons := make([]*Node, 0)
+	expanded := 0
+	var best *Path
+	bound := math.Inf(1)
+	for {
+		done := r.improvePath(epsilon, &incons, &expanded, opts.MaxExpanded, deadline)
+		if r.end.State != NODE_STATE_NORMAL {
+			sub := r.suboptimality(incons)
+			// 本轮完整结束时，路径成本不超过最短路径的ε倍
+			if done {
+				sub = math.Min(sub, epsilon)
+			}
+			if best == nil || r.end.G < best.Cost {
+				best = newPath(r.end, expanded)
+				e.mark(best)
+				bound = sub
+				if opts.OnImprove != nil && !opts.OnImprove(best, bound) {
+					break
+				}
+			} else {
+				bound = math.Min(bound, sub)
+			}
+		}
+		// 预算用完或已是最短路径
+		if !done || epsilon <= 1 || bound <= 1 {
+			break
+		}
+		epsilon = math.Max(1, epsilon-step)
+		r.nextRound(epsilon, &incons)
+	}
+	if best == nil {
+		if len(r.openList) == 0 {
+			return nil, 0, ErrNoPath
+		}
+		return nil, 0, ErrBudget
+	}
+	best.Expanded = expanded
+	return best, bound, nil
+}
+
+// 按当前膨胀系数扩展节点，直到终点的成本不大于开放列表的最小F
+// 预算用完时返回false
+func (r *Searcher) improvePath(epsilon float64, incons *[]*Node, expanded *int, maxExpanded int, deadline time.Time) bool {
+	for len(r.openList) > 0 {
+		if r.end.State != NODE_STATE_NORMAL && r.end.G <= r.openList[0].F {
+			return true
+		}
+		if maxExpanded > 0 && *expanded >= maxExpanded {
+			return false
+		}
+		if !deadline.IsZero() && *expanded%64 == 0 && time.Now().After(deadline) {
+			return false
+		}
+		node := r.openListPop()
+		r.closeListAppend(node)
+		*expanded++
+		for _, neighbor := range r.neighbors(node) {
+			g := node.G + r.cost(node, neighbor)
+			visited := neighbor.State != NODE_STATE_NORMAL || neighbor == r.start
+			if visited && g >= neighbor.G {
+				continue
+			}
+			neighbor.G = g
+			neighbor.H = r.heuristic(neighbor)
+			neighbor.F = neighbor.G + weigh(neighbor.H, epsilon)
+			neighbor.Parent = node
+			switch neighbor.State {
+			case NODE_STATE_OPENED:
+				heap.Fix(&r.openList, neighbor.index)
+			case NODE_STATE_CLOSED:
+				// 本轮已关闭，留到下一轮
+				neighbor.State = NODE_STATE_INCONS
+				*incons = append(*incons, neighbor)
+			case NODE_STATE_INCONS:
+			default:
+				r.openListAppend(neighbor)
+			}
+		}
+	}
+	return true
+}
+
+// 开始下一轮：不一致列表并入开放列表，按新的膨胀系数重建顺序，清空关闭列表
+func (r *Searcher) nextRound(epsilon float64, incons *[]*Node) {
+	for _, node := range *incons {
+		node.State = NODE_STATE_OPENED
+		r.openList = append(r.openList, node)
+	}
+	*incons = (*incons)[:0]
+	for i, node := range r.openList {
+		node.index = i
+		node.F = node.G + weigh(node.H, epsilon)
+	}
+	heap.Init(&r.openList)
+	for _, node := range r.closeList {
+		if node.State == NODE_STATE_CLOSED {
+			node.State = NODE_STATE_VISITED
+		}
+	}
+	r.closeList = r.closeList[:0]
+}
+
+// 当前路径成本与最短路径之比的上界
+// 最短路径不小于开放、不一致列表中最小的G+H
+func (r *Searcher) suboptimality(incons []*Node) float64 {
+	lower := r.end.G
+	for _, node := range r.openList {
+		lower = min(lower, node.G+node.H)
+	}
+	for _, node := range incons {
+		lower = min(lower, node.G+node.H)
+	}
+	if lower <= 0 {
+		return 1
+	}
+	return float64(r.end.G) / float64(lower)
+}
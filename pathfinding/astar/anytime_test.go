@@ -0,0 +1,98 @@
+package astar
+
+import (
+	"errors"
+	"math/rand"
+	"testing"
+)
+
+// 加权A*的路径成本不超过最短路径的Weight倍
+func TestWeightedBound(t *testing.T) {
+	rnd := rand.New(rand.NewSource(16))
+	r, err := NewAStar(randomMap(rnd, 40, 40, 0.2, true), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 200; i++ {
+		start, end := randomWalkable(rnd, r), randomWalkable(rnd, r)
+		r.Weight = 0
+		want, err := r.FindPath(&Node{X: start.X, Y: start.Y}, &Node{X: end.X, Y: end.Y})
+		if err != nil {
+			continue
+		}
+		for _, weight := range []float64{1.5, 3} {
+			r.Weight = weight
+			path, err := r.FindPath(&Node{X: start.X, Y: start.Y}, &Node{X: end.X, Y: end.Y})
+			if err != nil {
+				t.Fatalf("weight %v %v -> %v: %v", weight, start, end, err)
+			}
+			if float64(path.Cost) > weight*float64(want.Cost) {
+				t.Fatalf("weight %v %v -> %v: cost %d, shortest %d", weight, start, end, path.Cost, want.Cost)
+			}
+			checkPath(t, r, path, start, end)
+		}
+	}
+}
+
+// 随时搜索每次改进的路径都更便宜且不超过上界，不限预算时最终得到最短路径
+func TestFindPathAnytimeBound(t *testing.T) {
+	rnd := rand.New(rand.NewSource(17))
+	r, err := NewAStar(randomMap(rnd, 40, 40, 0.2, true), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 100; i++ {
+		start, end := randomWalkable(rnd, r), randomWalkable(rnd, r)
+		want, wantErr := r.FindPath(&Node{X: start.X, Y: start.Y}, &Node{X: end.X, Y: end.Y})
+		last := -1
+		opts := AnytimeOptions{
+			OnImprove: func(path *Path, bound float64) bool {
+				if last >= 0 && path.Cost >= last {
+					t.Fatalf("%v -> %v: cost %d after %d", start, end, path.Cost, last)
+				}
+				if float64(path.Cost) > bound*float64(want.Cost)+1e-9 {
+					t.Fatalf("%v -> %v: cost %d above bound %v of %d", start, end, path.Cost, bound, want.Cost)
+				}
+				last = path.Cost
+				return true
+			},
+		}
+		path, bound, err := r.FindPathAnytime(&Node{X: start.X, Y: start.Y}, &Node{X: end.X, Y: end.Y}, opts)
+		if (err == nil) != (wantErr == nil) {
+			t.Fatalf("%v -> %v: %v, FindPath %v", start, end, err, wantErr)
+		}
+		if err != nil {
+			continue
+		}
+		if path.Cost != want.Cost || bound != 1 || path.Cost != last {
+			t.Fatalf("%v -> %v: cost %d bound %v, shortest %d", start, end, path.Cost, bound, want.Cost)
+		}
+		checkPath(t, r, path, start, end)
+	}
+	// 预算内找不到路径
+	r, err = NewAStar(randomMap(rnd, 20, 20, 0, false), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _, err = r.FindPathAnytime(&Node{X: 0, Y: 0}, &Node{X: 19, Y: 19}, AnytimeOptions{MaxExpanded: 3})
+	if !errors.Is(err, ErrBudget) {
+		t.Fatalf("budget 3: %v, want ErrBudget", err)
+	}
+}
+
+// 每次改进的路径都带有起止点的替换标记
+func TestFindPathAnytimeResolve(t *testing.T) {
+	checkResolve(t, func(r *AStar, start, end *Node) (*Path, error) {
+		var improved []*Path
+		path, _, err := r.FindPathAnytime(start, end, AnytimeOptions{OnImprove: func(path *Path, bound float64) bool {
+			improved = append(improved, path)
+			return true
+		}})
+		for _, p := range improved {
+			if p.Redirected != path.Redirected || p.SnappedStart != path.SnappedStart || p.SnappedEnd != path.SnappedEnd {
+				t.Fatalf("improved path %+v, final %+v", p, path)
+			}
+		}
+		return path, err
+	})
+}
@@ -0,0 +1,592 @@
+package astar
+
+import (
+	"container/heap"
+	"context"
+	"fmt"
+	"math"
+	"pathfinding/grid"
+	"sync"
+)
+
+/*
+地图从左上角开始，水平x 垂直y
+  y
+x 0,0 1,0 2,0
+  0,1 1,1 2,1
+  0,2 1,2 2,2
+*/
+
+type (
+	Grid   = grid.Grid
+	Change = grid.Change
+	Point  = grid.Point
+)
+
+// 节点
+type Node struct {
+	// 坐标
+	X int
+	Y int
+	// 成本
+	F int
+	G int
+	H int
+	// 父节点
+	Parent *Node
+	// 类型
+	Type int
+	// 状态
+	State int
+	// 在开放列表（堆）中的下标
+	index int
+	// 所属搜索批次，与当前批次不同时搜索状态视为无效
+	search int
+}
+
+type AStar struct {
+	// 启发算法，为空时按移动方式选择
+	Heuristic func(node, end *Node) int
+	// 移动方式
+	Movement int
+	// 启发值膨胀系数ε，F = G + ε·H
+	// 大于1时搜索更快，路径成本不超过最短路径的ε倍，小于等于1时按1处理
+	Weight float64
+	// 单次搜索最多扩展的节点数，0表示不限
+	MaxExpanded int
+	// 终点不可达时改为寻路到与起点连通、离终点最近的格子，否则返回ErrNoPath
+	Redirect bool
+	// 起止点被障碍挡住时，在该半径（切比雪夫距离）内查找最近的可行格子代替，0表示不替换
+	SnapRadius int
+	// 地图大小
+	Rows int // y
+	Cols int // x
+	// 移动成本倍率（百分比），与mapData同样按[y][x]排列
+	// 为空时按节点类型取默认倍率
+	Costs [][]int
+	// 地图
+	grid *Grid
+	// 连通区域，按单位大小存放
+	components   map[int]*components
+	componentsMu sync.Mutex
+	// 跟随地图变化的分层寻路，Init更换地图时重新订阅
+	hpas  map[*HPA]bool
+	hpaMu sync.Mutex
+	// 寻路器池
+	pool sync.Pool
+}
+
+// 移动成本、移动方式、成本倍率和节点类型与地图相同
+const (
+	COST_STRAIGHT = grid.COST_STRAIGHT
+	COST_DIAGONAL = grid.COST_DIAGONAL
+
+	MOVEMENT_EIGHT               = grid.MOVEMENT_EIGHT
+	MOVEMENT_FOUR                = grid.MOVEMENT_FOUR
+	MOVEMENT_EIGHT_NO_CORNER_CUT = grid.MOVEMENT_EIGHT_NO_CORNER_CUT
+	MOVEMENT_EIGHT_NO_OBSTACLE   = grid.MOVEMENT_EIGHT_NO_OBSTACLE
+
+	COST_RATE_NORMAL = grid.COST_RATE_NORMAL
+	COST_RATE_ROAD   = grid.COST_RATE_ROAD
+	COST_RATE_SWAMP  = grid.COST_RATE_SWAMP
+	COST_RATE_WATER  = grid.COST_RATE_WATER
+
+	NODE_TYPE_NORMAL   = grid.NODE_TYPE_NORMAL
+	NODE_TYPE_OBSTACLE = grid.NODE_TYPE_OBSTACLE
+	NODE_TYPE_ROAD     = grid.NODE_TYPE_ROAD
+	NODE_TYPE_SWAMP    = grid.NODE_TYPE_SWAMP
+	NODE_TYPE_WATER    = grid.NODE_TYPE_WATER
+)
+
+// 节点状态
+const (
+	NODE_STATE_CLOSED = iota - 1
+	NODE_STATE_NORMAL
+	NODE_STATE_OPENED
+	NODE_STATE_VISITED // 已访问，不在开放、关闭列表中（随时搜索）
+	NODE_STATE_INCONS  // 关闭后成本又降低，等待下一轮搜索（随时搜索）
+)
+
+// 按数据生成寻路器，地图大小取自mapData
+// costData为空时按节点类型取默认成本倍率
+func NewAStar(mapData, costData [][]int) (*AStar, error) {
+	r := &AStar{
+		Rows:  len(mapData),
+		Costs: costData,
+	}
+	if r.Rows > 0 {
+		r.Cols = len(mapData[0])
+	}
+	if err := r.Init(mapData); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// 按Rows、Cols初始化地图，数据与地图大小不符时返回错误
+func (r *AStar) Init(mapData [][]int) error {
+	g, err := grid.NewGrid(r.Rows, r.Cols, mapData, r.Costs)
+	if err != nil {
+		return err
+	}
+	r.grid = g
+	r.components = map[int]*components{1: newComponents(g, 1)}
+	r.hpaMu.Lock()
+	hpas := make([]*HPA, 0, len(r.hpas))
+	for h := range r.hpas {
+		hpas = append(hpas, h)
+	}
+	r.hpaMu.Unlock()
+	for _, h := range hpas {
+		h.bind(g)
+	}
+	return nil
+}
+
+// 单位大小对应的连通区域，没有时生成并订阅地图变化，调用方不能持有地图的锁
+func (r *AStar) componentsFor(size int) *components {
+	r.componentsMu.Lock()
+	defer r.componentsMu.Unlock()
+	size = max(size, 1)
+	c, ok := r.components[size]
+	if !ok {
+		c = newComponents(r.grid, size)
+		r.components[size] = c
+	}
+	return c
+}
+
+// 已经生成的连通区域，没有时返回nil，持有地图的读锁时使用
+func (r *AStar) componentsOf(size int) *components {
+	r.componentsMu.Lock()
+	defer r.componentsMu.Unlock()
+	return r.components[max(size, 1)]
+}
+
+// 相邻节点坐标
+var (
+	straightPos = [][]int{
+		{0, -1}, // 上
+		{1, 0},  // 右
+		{0, 1},  // 下
+		{-1, 0}, // 左
+	}
+	allPos = [][]int{
+		{0, -1},  // 上
+		{1, -1},  // 右上
+		{1, 0},   // 右
+		{1, 1},   // 右下
+		{0, 1},   // 下
+		{-1, 1},  // 左下
+		{-1, 0},  // 左
+		{-1, -1}, // 左上
+	}
+)
+
+// 按移动方式取相邻节点坐标，不允许对角移动时去除对角坐标
+func (r *AStar) neighborPos() [][]int {
+	if r.Movement == MOVEMENT_FOUR {
+		return straightPos
+	}
+	return allPos
+}
+
+// 启发算法，未指定时与移动方式匹配
+func (r *AStar) heuristic(node, end *Node) int {
+	if r.Heuristic != nil {
+		return r.Heuristic(node, end)
+	}
+	if r.Movement == MOVEMENT_FOUR {
+		return Manhattan(node, end)
+	}
+	return Diagonal(node, end)
+}
+
+// 按膨胀系数放大启发值
+func (r *AStar) inflate(h int) int {
+	return weigh(h, r.Weight)
+}
+
+func weigh(h int, weight float64) int {
+	if weight <= 1 {
+		return h
+	}
+	return int(float64(h) * weight)
+}
+
+// 寻路，可被多个goroutine同时调用
+func (r *AStar) FindPath(start, end *Node) (*Path, error) {
+	s := r.Acquire()
+	defer r.Release(s)
+	return s.FindPath(start, end)
+}
+
+// 带取消和预算的寻路，可被多个goroutine同时调用
+// ctx取消、超时或扩展节点数超过MaxExpanded时，返回离终点最近的部分路径和ErrBudget
+func (r *AStar) FindPathContext(ctx context.Context, start, end *Node) (*Path, error) {
+	s := r.Acquire()
+	defer r.Release(s)
+	return s.FindPathContext(ctx, start, end)
+}
+
+// 为占据size*size个格子的单位寻路，可被多个goroutine同时调用
+// 节点坐标为单位左上角的格子，只经过净空不小于size的格子
+func (r *AStar) FindPathSize(start, end *Node, size int) (*Path, error) {
+	s := r.Acquire()
+	defer r.Release(s)
+	return s.FindPathSize(start, end, size)
+}
+
+func (r *Searcher) FindPath(start, end *Node) (*Path, error) {
+	return r.FindPathContext(context.Background(), start, end)
+}
+
+func (r *Searcher) FindPathSize(start, end *Node, size int) (*Path, error) {
+	// 订阅需要地图的写锁，在搜索持有读锁之前生成连通区域
+	r.astar.componentsFor(size)
+	r.size = size
+	defer func() { r.size = 0 }()
+	return r.FindPathContext(context.Background(), start, end)
+}
+
+func (r *Searcher) FindPathContext(ctx context.Context, start, end *Node) (*Path, error) {
+	r.astar.grid.RLock()
+	defer r.astar.grid.RUnlock()
+	e, err := r.resolve(start, end)
+	if err != nil {
+		return nil, err
+	}
+	node, err := r.find(ctx, e.start, e.end)
+	if err == nil && node == nil {
+		return nil, ErrNoPath
+	}
+	path := newPath(node, len(r.closeList))
+	path.Partial = err != nil
+	e.mark(path)
+	return path, err
+}
+
+// 寻路实际使用的起止点
+type endpoints struct {
+	start, end               *Node
+	snappedStart, snappedEnd bool
+	redirected               bool
+}
+
+// 在路径上标记起止点是否被替换
+func (e *endpoints) mark(path *Path) {
+	path.Redirected = e.redirected
+	path.SnappedStart = e.snappedStart
+	path.SnappedEnd = e.snappedEnd
+}
+
+// 确定寻路使用的起止点，调用方持有地图的读锁
+// 起止点被挡住时按SnapRadius替换为附近的可行格子，起止点不连通时返回ErrNoPath，
+// 设置了Redirect则把终点改为起点所在区域中离终点最近的格子
+func (r *Searcher) resolve(start, end *Node) (*endpoints, error) {
+	e := &endpoints{}
+	e.start, e.snappedStart = r.snap(start)
+	e.end, e.snappedEnd = r.snap(end)
+	if err := checkEndpoints(r.astar.grid, e.start, e.end); err != nil {
+		return nil, err
+	}
+	if err := r.checkSize(e.start, e.end); err != nil {
+		return nil, err
+	}
+	// 起止点不连通时不必搜索
+	from, to := Point{X: e.start.X, Y: e.start.Y}, Point{X: e.end.X, Y: e.end.Y}
+	if c := r.astar.componentsOf(r.size); c != nil && !c.connected(r.astar.Movement, from, to) {
+		if !r.astar.Redirect {
+			return nil, ErrNoPath
+		}
+		to, e.redirected = c.nearest(r.astar.Movement, from, to, func(x, y int) bool {
+			return r.astar.grid.Fits(x, y, r.size)
+		})
+		if !e.redirected {
+			return nil, ErrNoPath
+		}
+		e.end = &Node{X: to.X, Y: to.Y}
+	}
+	return e, nil
+}
+
+// 节点在地图内但单位无法站立时，按SnapRadius替换为最近的可行格子
+func (r *Searcher) snap(node *Node) (*Node, bool) {
+	g := r.astar.grid
+	if r.astar.SnapRadius <= 0 || !g.InBounds(node.X, node.Y) || g.Fits(node.X, node.Y, r.size) {
+		return node, false
+	}
+	p, ok := g.NearestFit(Point{X: node.X, Y: node.Y}, r.astar.SnapRadius, r.size)
+	if !ok {
+		return node, false
+	}
+	return &Node{X: p.X, Y: p.Y}, true
+}
+
+// 搜索使用的图，节点由寻路器（方格）或通用图的视图分配，同一个位置总是同一个节点
+type searchGraph interface {
+	// 可以从node移动到的相邻节点
+	neighbors(node *Node) []*Node
+	// 从node移动到相邻节点next的成本
+	cost(node, next *Node) int
+	// 节点到终点的启发值，不含膨胀系数
+	heuristic(node *Node) int
+}
+
+// 在方格地图上搜索，返回终点节点，沿Parent回溯可得路径
+// 预算用完时返回已扩展节点中离终点最近的节点和错误
+func (r *Searcher) find(ctx context.Context, start, end *Node) (*Node, error) {
+	r.reset()
+	return r.run(ctx, r, r.getNode(start.X, start.Y), r.getNode(end.X, end.Y))
+}
+
+// A*的搜索循环，方格寻路和通用图寻路共用
+// start、end是本轮搜索在g中分配的节点
+func (r *Searcher) run(ctx context.Context, g searchGraph, start, end *Node) (*Node, error) {
+	r.start, r.end = start, end
+	start.H = g.heuristic(start)
+	start.F = start.H
+	// 先把开始节点放进开放列表
+	r.openListAppend(start)
+	closest := start
+	for len(r.openList) > 0 {
+		if err := r.checkBudget(ctx, len(r.closeList)); err != nil {
+			return closest, err
+		}
+		node := r.openListPop()
+		// 判断当前节点是否是终点
+		if node == end {
+			if r.trace != nil {
+				r.traceStep(node, nil)
+			}
+			return node, nil
+		}
+		if node.H < closest.H || node.H == closest.H && node.G < closest.G {
+			closest = node
+		}
+		// 本次扩展中更新的相邻节点，只在跟踪时记录
+		var updated []*Node
+		// 找开放列表的第一个节点的相邻节点
+		neighbors := g.neighbors(node)
+		for _, neighbor := range neighbors {
+			// 是否在关闭列表
+			if neighbor.isClosed() {
+				continue
+			}
+			// 开始节点移动至相邻节点的成本
+			// 按移动方式（水平、垂直或对角）和相邻节点的地形计算
+			cost := node.G + g.cost(node, neighbor)
+			if !neighbor.isOpened() || cost < neighbor.G {
+				neighbor.G = cost
+				neighbor.H = g.heuristic(neighbor)
+				neighbor.F = neighbor.G + r.inflate(neighbor.H)
+				neighbor.Parent = node
+				// 优化逻辑，相邻节点是否是终点
+				// if r.isEnd(neighbor) {
+				// 	return neighbor
+				// }
+				if !neighbor.isOpened() {
+					r.openListAppend(neighbor)
+				} else {
+					// 成本降低，调整节点在堆中的位置
+					heap.Fix(&r.openList, neighbor.index)
+				}
+				if r.trace != nil {
+					updated = append(updated, neighbor)
+				}
+			}
+		}
+		if r.trace != nil {
+			r.traceStep(node, updated)
+		}
+		// 当前节点放进关闭列表
+		r.closeListAppend(node)
+	}
+	return nil, nil
+}
+
+// 检查搜索预算，每扩展64个节点检查一次ctx
+func (r *Searcher) checkBudget(ctx context.Context, expanded int) error {
+	if !r.exact && r.astar.MaxExpanded > 0 && expanded >= r.astar.MaxExpanded {
+		return ErrBudget
+	}
+	if expanded%64 == 0 {
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%w: %w", ErrBudget, ctx.Err())
+		default:
+		}
+	}
+	return nil
+}
+
+// 按膨胀系数放大启发值，exact为true时不放大
+func (r *Searcher) inflate(h int) int {
+	if r.exact {
+		return h
+	}
+	return r.astar.inflate(h)
+}
+
+// 查找相邻节点位置
+func (r *Searcher) neighbors(node *Node) []*Node {
+	neighbors := make([]*Node, 0)
+	for _, v := range r.astar.neighborPos() {
+		// 检测节点是否非法
+		if !r.canMove(node.X, node.Y, v[0], v[1]) {
+			continue
+		}
+		x, y := node.X+v[0], node.Y+v[1]
+		// 是否超出搜索范围
+		if r.limit != nil && !r.limit.contains(x, y) {
+			continue
+		}
+		neighbors = append(neighbors, r.getNode(x, y))
+	}
+	return neighbors
+}
+
+// 从node移动到相邻节点next的成本
+func (r *Searcher) cost(node, next *Node) int {
+	return r.astar.grid.StepCost(node.X, node.Y, next.X, next.Y)
+}
+
+// 节点到终点的启发值
+func (r *Searcher) heuristic(node *Node) int {
+	return r.astar.grid.ScaleHeuristic(r.astar.heuristic(node, r.end))
+}
+
+func (r *Searcher) isEnd(node *Node) bool {
+	return node.X == r.end.X && node.Y == r.end.Y
+}
+
+// 地图，修改格子或订阅变化
+func (r *AStar) Grid() *Grid {
+	return r.grid
+}
+
+// 两个格子是否可行且能互相到达
+func (r *AStar) Connected(a, b Point) bool {
+	r.grid.RLock()
+	defer r.grid.RUnlock()
+	if !r.grid.InBounds(a.X, a.Y) || !r.grid.InBounds(b.X, b.Y) {
+		return false
+	}
+	return r.componentsOf(1).connected(r.Movement, a, b)
+}
+
+func (r *AStar) isWalkable(x, y int) bool {
+	return r.grid.IsWalkable(x, y)
+}
+
+// 按单位大小判断能否从x,y向dx,dy方向移动一格
+func (r *Searcher) canMove(x, y, dx, dy int) bool {
+	return r.astar.grid.CanMoveSize(r.astar.Movement, r.size, x, y, dx, dy)
+}
+
+// 检查单位在起止点能否容纳
+func (r *Searcher) checkSize(start, end *Node) error {
+	if !r.astar.grid.Fits(start.X, start.Y, r.size) {
+		return fmt.Errorf("start %d,%d: size %d does not fit: %w", start.X, start.Y, r.size, ErrStartBlocked)
+	}
+	if !r.astar.grid.Fits(end.X, end.Y, r.size) {
+		return fmt.Errorf("end %d,%d: size %d does not fit: %w", end.X, end.Y, r.size, ErrEndBlocked)
+	}
+	return nil
+}
+
+func (node *Node) isWalkable() bool {
+	return node.Type != NODE_TYPE_OBSTACLE
+}
+
+func (node *Node) isOpened() bool {
+	return node.State == NODE_STATE_OPENED
+}
+
+func (node *Node) isClosed() bool {
+	return node.State == NODE_STATE_CLOSED
+}
+
+func (r *Searcher) openListAppend(node *Node) {
+	node.State = NODE_STATE_OPENED
+	heap.Push(&r.openList, node)
+}
+
+// 取出F最小的节点
+func (r *Searcher) openListPop() *Node {
+	if len(r.openList) == 0 {
+		return nil
+	}
+	return heap.Pop(&r.openList).(*Node)
+}
+
+func (r *Searcher) closeListAppend(node *Node) {
+	node.State = NODE_STATE_CLOSED
+	r.closeList = append(r.closeList, node)
+}
+
+// 打印路径、导航图和上一次搜索的开放、关闭列表
+func (r *Searcher) Print(path *Path, mapData [][]int) {
+	fmt.Println("导航路径：")
+	onPath := make(map[Point]bool, len(path.Points))
+	for i := len(path.Points) - 1; i >= 0; i-- {
+		node := r.getNode(path.Points[i].X, path.Points[i].Y)
+		fmt.Printf("x,y: %d,%d cost: f%d h%d g%d \n", node.X, node.Y, node.F, node.H, node.G)
+		onPath[path.Points[i]] = true
+	}
+	fmt.Println("导航图：")
+	for i := 0; i < len(mapData); i++ {
+		for j := 0; j < len(mapData[i]); j++ {
+			if onPath[Point{X: j, Y: i}] {
+				fmt.Print("* ")
+			} else {
+				fmt.Print(mapData[i][j], " ")
+			}
+		}
+		fmt.Print("\n")
+	}
+	fmt.Println("准备扫描节点：")
+	for _, node := range r.openList {
+		fmt.Printf("x,y: %d,%d \n", node.X, node.Y)
+	}
+	fmt.Println("已扫描节点：")
+	for _, node := range r.closeList {
+		fmt.Printf("x,y: %d,%d \n", node.X, node.Y)
+	}
+}
+
+// 曼哈顿
+func Manhattan(node, end *Node) int {
+	x := abs(node.X - end.X)
+	y := abs(node.Y - end.Y)
+	return (x + y) * COST_STRAIGHT
+}
+
+// 对角线
+func Diagonal(node, end *Node) int {
+	x := abs(node.X - end.X)
+	y := abs(node.Y - end.Y)
+	min := min(x, y)
+	return min*COST_DIAGONAL + abs(x-y)*COST_STRAIGHT
+}
+
+// 欧几里得
+func Euclidean(node, end *Node) int {
+	x := abs(node.X - end.X)
+	y := abs(node.Y - end.Y)
+	v := float64(x)*float64(x) + float64(y)*float64(y)
+	return int(math.Sqrt(v) * COST_STRAIGHT)
+}
+
+func abs(n int) int {
+	y := n >> 63
+	return (n ^ y) - y
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	} else {
+		return b
+	}
+}
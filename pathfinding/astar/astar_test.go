@@ -0,0 +1,516 @@
+package astar
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync"
+	"testing"
+	"time"
+)
+
+// 随机地图，obstacles为障碍的比例，terrain为true时可行格子随机取地形
+func randomMap(rnd *rand.Rand, rows, cols int, obstacles float64, terrain bool) [][]int {
+	types := []int{NODE_TYPE_NORMAL, NODE_TYPE_ROAD, NODE_TYPE_SWAMP, NODE_TYPE_WATER}
+	mapData := make([][]int, rows)
+	for y := range mapData {
+		mapData[y] = make([]int, cols)
+		for x := range mapData[y] {
+			switch {
+			case rnd.Float64() < obstacles:
+				mapData[y][x] = NODE_TYPE_OBSTACLE
+			case terrain:
+				mapData[y][x] = types[rnd.Intn(len(types))]
+			}
+		}
+	}
+	return mapData
+}
+
+// 随机取一个可行格子
+func randomWalkable(rnd *rand.Rand, r *AStar) Point {
+	for {
+		p := Point{X: rnd.Intn(r.Cols), Y: rnd.Intn(r.Rows)}
+		if r.grid.IsWalkable(p.X, p.Y) {
+			return p
+		}
+	}
+}
+
+// 检查路径从start到end，每一步都符合移动方式，成本等于每一步的成本之和
+func checkPath(t *testing.T, r *AStar, path *Path, start, end Point) {
+	t.Helper()
+	if len(path.Points) == 0 || path.Points[0] != start || path.Points[len(path.Points)-1] != end {
+		t.Fatalf("path %v does not run from %v to %v", path.Points, start, end)
+	}
+	cost := 0
+	for i := 1; i < len(path.Points); i++ {
+		a, b := path.Points[i-1], path.Points[i]
+		dx, dy := b.X-a.X, b.Y-a.Y
+		if abs(dx) > 1 || abs(dy) > 1 || !r.grid.CanMove(r.Movement, a.X, a.Y, dx, dy) {
+			t.Fatalf("illegal step %v -> %v in %v", a, b, path.Points)
+		}
+		cost += r.grid.StepCost(a.X, a.Y, b.X, b.Y)
+	}
+	if cost != path.Cost {
+		t.Fatalf("path cost %d, steps add up to %d", path.Cost, cost)
+	}
+}
+
+// 起止点随机，可能越界、被挡住或不可达，设置SnapRadius和Redirect后，
+// find与FindPath返回相同的错误，路径的首尾和替换标记也相同
+func checkResolve(t *testing.T, find func(r *AStar, start, end *Node) (*Path, error)) {
+	t.Helper()
+	rnd := rand.New(rand.NewSource(18))
+	movements := []int{MOVEMENT_FOUR, MOVEMENT_EIGHT, MOVEMENT_EIGHT_NO_CORNER_CUT, MOVEMENT_EIGHT_NO_OBSTACLE}
+	snapped, redirected := 0, 0
+	var r *AStar
+	for i := 0; i < 200; i++ {
+		if i%50 == 0 {
+			var err error
+			if r, err = NewAStar(randomMap(rnd, 20, 20, 0.3, false), nil); err != nil {
+				t.Fatal(err)
+			}
+			r.SnapRadius = 2
+			r.Movement = movements[i/50]
+		}
+		r.Redirect = i%2 == 0
+		start := &Node{X: rnd.Intn(22) - 1, Y: rnd.Intn(22) - 1}
+		end := &Node{X: rnd.Intn(22) - 1, Y: rnd.Intn(22) - 1}
+		want, wantErr := r.FindPath(start, end)
+		got, err := find(r, start, end)
+		if fmt.Sprint(err) != fmt.Sprint(wantErr) {
+			t.Fatalf("%v -> %v: %v, FindPath %v", start, end, err, wantErr)
+		}
+		if err != nil {
+			continue
+		}
+		if got.Points[0] != want.Points[0] || got.Points[len(got.Points)-1] != want.Points[len(want.Points)-1] ||
+			got.Redirected != want.Redirected || got.SnappedStart != want.SnappedStart || got.SnappedEnd != want.SnappedEnd {
+			t.Fatalf("%v -> %v: %+v, FindPath %+v", start, end, got, want)
+		}
+		if got.SnappedStart || got.SnappedEnd {
+			snapped++
+		}
+		if got.Redirected {
+			redirected++
+		}
+	}
+	if snapped == 0 || redirected == 0 {
+		t.Fatalf("%d snapped and %d redirected paths, want both", snapped, redirected)
+	}
+}
+
+// 同一个AStar连续寻路，结果与每次重新Init后寻路相同
+func TestFindPathRepeated(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	const rows, cols = 20, 20
+	mapData := randomMap(rnd, rows, cols, 0.25, false)
+	r, err := NewAStar(mapData, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 200; i++ {
+		start := &Node{X: rnd.Intn(cols), Y: rnd.Intn(rows)}
+		end := &Node{X: rnd.Intn(cols), Y: rnd.Intn(rows)}
+		fresh, err := NewAStar(mapData, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want, wantErr := fresh.FindPath(start, end)
+		got, err := r.FindPath(start, end)
+		if (err == nil) != (wantErr == nil) {
+			t.Fatalf("%v -> %v: reused %v, fresh %v", start, end, err, wantErr)
+		}
+		if err == nil && got.Cost != want.Cost {
+			t.Fatalf("%v -> %v: reused cost %d, fresh cost %d", start, end, got.Cost, want.Cost)
+		}
+	}
+}
+
+// 多个goroutine同时在同一个AStar上寻路，结果与依次寻路相同，用go test -race检查数据竞争
+func TestFindPathConcurrent(t *testing.T) {
+	rnd := rand.New(rand.NewSource(13))
+	const rows, cols = 40, 40
+	r, err := NewAStar(randomMap(rnd, rows, cols, 0.2, false), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	queries := make([][2]*Node, 400)
+	want := make([]int, len(queries))
+	for i := range queries {
+		queries[i] = [2]*Node{{X: rnd.Intn(cols), Y: rnd.Intn(rows)}, {X: rnd.Intn(cols), Y: rnd.Intn(rows)}}
+		want[i] = pathCost(r.FindPath(queries[i][0], queries[i][1]))
+	}
+	var wg sync.WaitGroup
+	errs := make(chan error, 8)
+	for w := 0; w < 8; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := w; i < len(queries); i += 8 {
+				if cost := pathCost(r.FindPath(queries[i][0], queries[i][1])); cost != want[i] {
+					errs <- fmt.Errorf("%v -> %v: cost %d, sequential %d", queries[i][0], queries[i][1], cost, want[i])
+					return
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatal(err)
+	}
+}
+
+// 多个goroutine同时寻路和修改地图，用go test -race检查数据竞争
+func TestFindPathConcurrentEdits(t *testing.T) {
+	rnd := rand.New(rand.NewSource(13))
+	r, err := NewAStar(randomMap(rnd, 40, 40, 0.2, true), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Movement = MOVEMENT_EIGHT_NO_CORNER_CUT
+	done := make(chan struct{})
+	var edits sync.WaitGroup
+	for w := 0; w < 2; w++ {
+		edits.Add(1)
+		go func(seed int64) {
+			defer edits.Done()
+			rnd := rand.New(rand.NewSource(seed))
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				r.Grid().SetWalkable(rnd.Intn(40), rnd.Intn(40), rnd.Intn(3) != 0)
+			}
+		}(int64(w))
+	}
+	var searches sync.WaitGroup
+	errs := make(chan error, 8)
+	for w := 0; w < 8; w++ {
+		searches.Add(1)
+		go func(seed int64) {
+			defer searches.Done()
+			rnd := rand.New(rand.NewSource(seed))
+			for i := 0; i < 50; i++ {
+				start := &Node{X: rnd.Intn(40), Y: rnd.Intn(40)}
+				end := &Node{X: rnd.Intn(40), Y: rnd.Intn(40)}
+				path, err := r.FindPathContext(context.Background(), start, end)
+				if err == nil && (path.Points[0] != Point{X: start.X, Y: start.Y} || path.Points[len(path.Points)-1] != Point{X: end.X, Y: end.Y}) {
+					errs <- fmt.Errorf("path %v does not run from %v to %v", path.Points, start, end)
+					return
+				}
+				if err != nil && !errors.Is(err, ErrNoPath) && !errors.Is(err, ErrStartBlocked) && !errors.Is(err, ErrEndBlocked) {
+					errs <- err
+					return
+				}
+			}
+		}(int64(100 + w))
+	}
+	searches.Wait()
+	close(done)
+	edits.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatal(err)
+	}
+}
+
+// 路径成本，找不到路径时为-1
+func pathCost(path *Path, err error) int {
+	if err != nil {
+		return -1
+	}
+	return path.Cost
+}
+
+// 越界、起止点是障碍、无法到达时返回对应的错误，成功时路径从起点到终点
+func TestFindPathErrors(t *testing.T) {
+	mapData := [][]int{
+		{0, 0, 0, 1, 0},
+		{0, 1, 0, 1, 0},
+		{0, 1, 0, 1, 1},
+	}
+	r, err := NewAStar(mapData, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		start, end Point
+		err        error
+	}{
+		{Point{X: -1, Y: 0}, Point{X: 0, Y: 0}, ErrOutOfBounds},
+		{Point{X: 0, Y: 0}, Point{X: 5, Y: 0}, ErrOutOfBounds},
+		{Point{X: 1, Y: 1}, Point{X: 0, Y: 0}, ErrStartBlocked},
+		{Point{X: 0, Y: 0}, Point{X: 3, Y: 0}, ErrEndBlocked},
+		{Point{X: 0, Y: 0}, Point{X: 4, Y: 1}, ErrNoPath},
+	}
+	for _, tt := range tests {
+		_, err := r.FindPath(&Node{X: tt.start.X, Y: tt.start.Y}, &Node{X: tt.end.X, Y: tt.end.Y})
+		if !errors.Is(err, tt.err) {
+			t.Errorf("%v -> %v: %v, want %v", tt.start, tt.end, err, tt.err)
+		}
+	}
+	path, err := r.FindPath(&Node{X: 0, Y: 0}, &Node{X: 2, Y: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	last := path.Points[len(path.Points)-1]
+	if path.Points[0] != (Point{X: 0, Y: 0}) || last != (Point{X: 2, Y: 2}) || path.Cost != 34 || path.Expanded == 0 {
+		t.Fatalf("path %v cost %d expanded %d", path.Points, path.Cost, path.Expanded)
+	}
+}
+
+// 随机地形上每种移动方式的路径成本都与启发值为0的搜索（Dijkstra）相同
+func TestFindPathOptimalOnTerrain(t *testing.T) {
+	rnd := rand.New(rand.NewSource(14))
+	zero := func(node, end *Node) int { return 0 }
+	for m := 0; m < 4; m++ {
+		mapData := randomMap(rnd, 30, 30, 0.2, true)
+		// 一半的地图使用自定义倍率，最低倍率低于道路，启发值需要按它缩放
+		var costs [][]int
+		if m%2 == 1 {
+			costs = make([][]int, 30)
+			for y := range costs {
+				costs[y] = make([]int, 30)
+				for x := range costs[y] {
+					costs[y][x] = 20 + rnd.Intn(500)
+				}
+			}
+		}
+		r, err := NewAStar(mapData, costs)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, movement := range []int{MOVEMENT_FOUR, MOVEMENT_EIGHT, MOVEMENT_EIGHT_NO_CORNER_CUT, MOVEMENT_EIGHT_NO_OBSTACLE} {
+			r.Movement = movement
+			for i := 0; i < 100; i++ {
+				start, end := randomWalkable(rnd, r), randomWalkable(rnd, r)
+				r.Heuristic = nil
+				path, err := r.FindPath(&Node{X: start.X, Y: start.Y}, &Node{X: end.X, Y: end.Y})
+				r.Heuristic = zero
+				want, wantErr := r.FindPath(&Node{X: start.X, Y: start.Y}, &Node{X: end.X, Y: end.Y})
+				if (err == nil) != (wantErr == nil) {
+					t.Fatalf("movement %d %v -> %v: %v, zero heuristic %v", movement, start, end, err, wantErr)
+				}
+				if err != nil {
+					continue
+				}
+				if path.Cost != want.Cost {
+					t.Fatalf("movement %d %v -> %v: cost %d, shortest %d", movement, start, end, path.Cost, want.Cost)
+				}
+				checkPath(t, r, path, start, end)
+			}
+		}
+	}
+}
+
+// 斜向移动经过的两侧格子决定各移动方式能否通过
+func TestMovementPolicies(t *testing.T) {
+	tests := []struct {
+		mapData [][]int
+		// 依次为MOVEMENT_EIGHT、FOUR、EIGHT_NO_CORNER_CUT、EIGHT_NO_OBSTACLE的成本，-1为无路径
+		costs [4]int
+	}{
+		// 两侧都是障碍
+		{[][]int{{0, 1}, {1, 0}}, [4]int{14, -1, -1, -1}},
+		// 一侧是障碍
+		{[][]int{{0, 0}, {1, 0}}, [4]int{14, 20, 14, 20}},
+		// 两侧都可行
+		{[][]int{{0, 0}, {0, 0}}, [4]int{14, 20, 14, 14}},
+	}
+	for _, tt := range tests {
+		for movement, want := range tt.costs {
+			r, err := NewAStar(tt.mapData, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			r.Movement = movement
+			if got := pathCost(r.FindPath(&Node{X: 0, Y: 0}, &Node{X: 1, Y: 1})); got != want {
+				t.Errorf("map %v movement %d: cost %d, want %d", tt.mapData, movement, got, want)
+			}
+		}
+	}
+	// 4方向默认使用曼哈顿距离，不会高估
+	r, err := NewAStar([][]int{{0, 0}, {0, 0}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Movement = MOVEMENT_FOUR
+	if h := r.heuristic(&Node{X: 0, Y: 0}, &Node{X: 1, Y: 1}); h != 2*COST_STRAIGHT {
+		t.Fatalf("four-way heuristic %d, want %d", h, 2*COST_STRAIGHT)
+	}
+}
+
+// 地图数据不合法时NewAStar返回ErrInvalidMap
+func TestNewAStarInvalidMap(t *testing.T) {
+	tests := []struct {
+		name     string
+		mapData  [][]int
+		costData [][]int
+	}{
+		{"empty", nil, nil},
+		{"empty row", [][]int{{}}, nil},
+		{"jagged", [][]int{{0, 0}, {0}}, nil},
+		{"unknown type", [][]int{{0, 9}}, nil},
+		{"cost rows", [][]int{{0, 0}, {0, 0}}, [][]int{{100, 100}}},
+		{"cost row size", [][]int{{0, 0}, {0, 0}}, [][]int{{100, 100}, {100}}},
+		{"zero rate", [][]int{{0, 0}}, [][]int{{100, 0}}},
+		{"negative rate", [][]int{{0, 0}}, [][]int{{-50, 100}}},
+	}
+	for _, tt := range tests {
+		if _, err := NewAStar(tt.mapData, tt.costData); !errors.Is(err, ErrInvalidMap) {
+			t.Errorf("%s: %v, want ErrInvalidMap", tt.name, err)
+		}
+	}
+	// Init按Rows、Cols检查，行数不符同样报错
+	r, err := NewAStar([][]int{{0, 0}, {0, 0}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Rows = 3
+	if err := r.Init([][]int{{0, 0}, {0, 0}}); !errors.Is(err, ErrInvalidMap) {
+		t.Fatalf("row count mismatch: %v, want ErrInvalidMap", err)
+	}
+	if r, err = NewAStar([][]int{{0, 3}, {4, 2}}, [][]int{{100, 80}, {120, 100}}); err != nil || r.Rows != 2 || r.Cols != 2 {
+		t.Fatalf("valid map: %v, size %dx%d", err, r.Rows, r.Cols)
+	}
+}
+
+// 扩展节点数用完、ctx取消或超时时返回部分路径和ErrBudget，部分路径从起点出发
+func TestFindPathBudget(t *testing.T) {
+	// 竖墙交替在最下和最上一行留缺口，路径需要来回绕行
+	mapData := make([][]int, 20)
+	for y := range mapData {
+		mapData[y] = make([]int, 20)
+		for x := 1; x < 20; x += 2 {
+			if x%4 == 1 && y != 19 || x%4 == 3 && y != 0 {
+				mapData[y][x] = NODE_TYPE_OBSTACLE
+			}
+		}
+	}
+	r, err := NewAStar(mapData, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	start, end := &Node{X: 0, Y: 0}, &Node{X: 18, Y: 0}
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	expired, cancel := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancel()
+	tests := []struct {
+		name        string
+		ctx         context.Context
+		maxExpanded int
+		err         error
+	}{
+		{"max expanded", context.Background(), 10, ErrBudget},
+		{"cancelled", cancelled, 0, context.Canceled},
+		{"deadline", expired, 0, context.DeadlineExceeded},
+	}
+	for _, tt := range tests {
+		r.MaxExpanded = tt.maxExpanded
+		path, err := r.FindPathContext(tt.ctx, start, end)
+		if !errors.Is(err, ErrBudget) || !errors.Is(err, tt.err) {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if path == nil || !path.Partial || path.Points[0] != (Point{X: 0, Y: 0}) || path.Expanded > tt.maxExpanded {
+			t.Fatalf("%s: partial path %+v", tt.name, path)
+		}
+		checkPath(t, r, path, Point{X: 0, Y: 0}, path.Points[len(path.Points)-1])
+	}
+	r.MaxExpanded = 0
+	path, err := r.FindPathContext(context.Background(), start, end)
+	if err != nil || path.Partial || path.Points[len(path.Points)-1] != (Point{X: 18, Y: 0}) {
+		t.Fatalf("unlimited: %v, %+v", err, path)
+	}
+}
+
+// 2x2的单位只能从宽的缺口通过，路径上每个格子都能容纳单位，容纳不下的起点返回ErrStartBlocked
+func TestFindPathSize(t *testing.T) {
+	mapData := [][]int{
+		{0, 0, 0, 0, 0, 0},
+		{0, 0, 0, 0, 0, 0},
+		{1, 1, 0, 1, 1, 1},
+		{0, 0, 0, 0, 0, 0},
+		{0, 0, 0, 0, 0, 0},
+		{1, 1, 1, 0, 0, 1},
+		{0, 0, 0, 0, 0, 0},
+		{0, 0, 0, 0, 0, 0},
+	}
+	r, err := NewAStar(mapData, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	start, end := Point{X: 0, Y: 3}, Point{X: 0, Y: 6}
+	path, err := r.FindPathSize(&Node{X: start.X, Y: start.Y}, &Node{X: end.X, Y: end.Y}, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkPath(t, r, path, start, end)
+	for _, p := range path.Points {
+		if r.Grid().Clearance(p.X, p.Y) < 2 {
+			t.Fatalf("size 2 does not fit at %v in %v", p, path.Points)
+		}
+	}
+	// 第一排的缺口只有一格宽
+	_, err = r.FindPathSize(&Node{X: 0, Y: 0}, &Node{X: 0, Y: 3}, 2)
+	if !errors.Is(err, ErrNoPath) {
+		t.Fatalf("narrow gap: %v", err)
+	}
+	if _, err := r.FindPath(&Node{X: 0, Y: 0}, &Node{X: 0, Y: 3}); err != nil {
+		t.Fatalf("narrow gap, size 1: %v", err)
+	}
+	_, err = r.FindPathSize(&Node{X: 5, Y: 4}, &Node{X: 0, Y: 6}, 2)
+	if !errors.Is(err, ErrStartBlocked) {
+		t.Fatalf("start at the right edge: %v", err)
+	}
+}
+
+// 起止点被挡住时按SnapRadius替换为最近的可行格子，半径内没有可行格子或半径为0时返回原来的错误
+func TestFindPathSnap(t *testing.T) {
+	mapData := [][]int{
+		{0, 0, 0, 0, 0, 0, 0},
+		{0, 1, 0, 0, 0, 1, 1},
+		{0, 0, 0, 0, 0, 1, 1},
+		{0, 0, 0, 0, 0, 1, 1},
+	}
+	r, err := NewAStar(mapData, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	blockedStart, blockedEnd := &Node{X: 1, Y: 1}, &Node{X: 6, Y: 3}
+	if _, err := r.FindPath(blockedStart, &Node{X: 4, Y: 0}); !errors.Is(err, ErrStartBlocked) {
+		t.Fatalf("radius 0, blocked start: %v", err)
+	}
+	r.SnapRadius = 1
+	if _, err := r.FindPath(&Node{X: 0, Y: 0}, blockedEnd); !errors.Is(err, ErrEndBlocked) {
+		t.Fatalf("radius 1, no walkable cell in range: %v", err)
+	}
+	if _, err := r.FindPath(&Node{X: 0, Y: 0}, &Node{X: 7, Y: 0}); !errors.Is(err, ErrOutOfBounds) {
+		t.Fatalf("out of bounds end: %v", err)
+	}
+	// 直线相邻的格子比对角相邻的格子近
+	path, err := r.FindPath(blockedStart, &Node{X: 4, Y: 0})
+	if err != nil {
+		t.Fatal(err)
+	}
+	first := path.Points[0]
+	if !path.SnappedStart || path.SnappedEnd || abs(first.X-1)+abs(first.Y-1) != 1 {
+		t.Fatalf("snapped start: %+v", path)
+	}
+	checkPath(t, r, path, first, Point{X: 4, Y: 0})
+	r.SnapRadius = 2
+	path, err = r.FindPath(&Node{X: 0, Y: 0}, blockedEnd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if path.SnappedStart || !path.SnappedEnd || path.Points[len(path.Points)-1] != (Point{X: 4, Y: 3}) {
+		t.Fatalf("snapped end: %+v", path)
+	}
+	checkPath(t, r, path, Point{X: 0, Y: 0}, Point{X: 4, Y: 3})
+}
@@ -0,0 +1,103 @@
+package astar
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+// 大地图上从左上角到右下角的寻路，比较堆实现的开放列表和原来每轮排序的开放列表
+func BenchmarkFindPath(b *testing.B) {
+	for _, size := range []int{256, 1024} {
+		mapData := benchmarkMap(size)
+		r, err := NewAStar(mapData, nil)
+		if err != nil {
+			b.Fatal(err)
+		}
+		start, end := &Node{X: 0, Y: 0}, &Node{X: size - 1, Y: size - 1}
+		path, err := r.FindPath(start, end)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if cost := findPathSorted(r, mapData, start, end); cost != path.Cost {
+			b.Fatalf("size %d: sorted open list cost %d, heap %d", size, cost, path.Cost)
+		}
+		b.Run(fmt.Sprintf("heap/%d", size), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				r.FindPath(start, end)
+			}
+		})
+		b.Run(fmt.Sprintf("sorted/%d", size), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				findPathSorted(r, mapData, start, end)
+			}
+		})
+	}
+}
+
+// 15%障碍的随机地图，起止点所在的角可行且连通
+func benchmarkMap(size int) [][]int {
+	for seed := int64(1); ; seed++ {
+		mapData := randomMap(rand.New(rand.NewSource(seed)), size, size, 0.15, false)
+		mapData[0][0], mapData[size-1][size-1] = NODE_TYPE_NORMAL, NODE_TYPE_NORMAL
+		r := &AStar{}
+		if findPathSorted(r, mapData, &Node{X: 0, Y: 0}, &Node{X: size - 1, Y: size - 1}) >= 0 {
+			return mapData
+		}
+	}
+}
+
+// 原来的开放列表：每轮按F排序整个列表，取出第一个后整体前移
+func findPathSorted(r *AStar, mapData [][]int, start, end *Node) int {
+	rows, cols := len(mapData), len(mapData[0])
+	id := func(x, y int) int { return x*rows + y }
+	costs := make([]int, rows*cols)
+	f := make([]int, rows*cols)
+	// 0未访问，1在开放列表，2已关闭
+	state := make([]byte, rows*cols)
+	heuristic := func(x, y int) int {
+		return r.heuristic(&Node{X: x, Y: y}, end)
+	}
+	first := id(start.X, start.Y)
+	f[first] = heuristic(start.X, start.Y)
+	state[first] = 1
+	openList := []int{first}
+	for len(openList) > 0 {
+		sort.Slice(openList, func(i, j int) bool {
+			return f[openList[i]] < f[openList[j]]
+		})
+		node := openList[0]
+		openList = openList[1:]
+		x, y := node/rows, node%rows
+		if x == end.X && y == end.Y {
+			return costs[node]
+		}
+		state[node] = 2
+		for dx := -1; dx <= 1; dx++ {
+			for dy := -1; dy <= 1; dy++ {
+				nx, ny := x+dx, y+dy
+				if (dx == 0 && dy == 0) || nx < 0 || ny < 0 || nx >= cols || ny >= rows || mapData[ny][nx] == NODE_TYPE_OBSTACLE {
+					continue
+				}
+				next := id(nx, ny)
+				if state[next] == 2 {
+					continue
+				}
+				cost := costs[node] + COST_STRAIGHT
+				if dx != 0 && dy != 0 {
+					cost = costs[node] + COST_DIAGONAL
+				}
+				if state[next] == 0 || cost < costs[next] {
+					costs[next] = cost
+					f[next] = cost + heuristic(nx, ny)
+					if state[next] == 0 {
+						state[next] = 1
+						openList = append(openList, next)
+					}
+				}
+			}
+		}
+	}
+	return -1
+}
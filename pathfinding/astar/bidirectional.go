@@ -0,0 +1,101 @@
+package astar
+
+import (
+	"container/heap"
+	"math"
+)
+
+/*
+双向A*
+正向从起点搜索终点，反向从终点搜索起点，两个方向各用一个寻路器
+每次扩展开放列表较小的一侧，相邻节点已被另一侧访问时记录相遇的路径
+当任意一侧开放列表的最小F不小于已知最短路径时，不可能再找到更短的路径，搜索结束
+*/
+
+// 双向寻路，可被多个goroutine同时调用
+// 起止点的处理（SnapRadius、Redirect、连通区域）与FindPath相同
+func (r *AStar) FindPathBidirectional(start, end *Node) (*Path, error) {
+	r.grid.RLock()
+	defer r.grid.RUnlock()
+	forward := r.Acquire()
+	defer r.Release(forward)
+	backward := r.Acquire()
+	defer r.Release(backward)
+	e, err := forward.resolve(start, end)
+	if err != nil {
+		return nil, err
+	}
+	forward.begin(e.start, e.end)
+	backward.begin(e.end, e.start)
+	// 已知最短路径的成本和两侧的相遇节点
+	best := math.MaxInt
+	var meetForward, meetBackward *Node
+	if forward.isEnd(forward.start) {
+		best, meetForward, meetBackward = 0, forward.start, backward.start
+	}
+	for len(forward.openList) > 0 && len(backward.openList) > 0 {
+		if forward.openList[0].F >= best || backward.openList[0].F >= best {
+			break
+		}
+		// 扩展开放列表较小的一侧
+		s, other, reverse := forward, backward, false
+		if len(backward.openList) < len(forward.openList) {
+			s, other, reverse = backward, forward, true
+		}
+		node := s.openListPop()
+		s.closeListAppend(node)
+		for _, neighbor := range s.neighbors(node) {
+			if neighbor.isClosed() {
+				continue
+			}
+			// 反向搜索经过的是从neighbor到node的移动
+			var g int
+			if reverse {
+				g = node.G + s.cost(neighbor, node)
+			} else {
+				g = node.G + s.cost(node, neighbor)
+			}
+			if !neighbor.isOpened() || g < neighbor.G {
+				neighbor.G = g
+				neighbor.H = s.heuristic(neighbor)
+				neighbor.F = neighbor.G + neighbor.H
+				neighbor.Parent = node
+				if !neighbor.isOpened() {
+					s.openListAppend(neighbor)
+				} else {
+					heap.Fix(&s.openList, neighbor.index)
+				}
+			}
+			// 另一侧已经到达过该节点，两侧路径在此相遇
+			meet := other.visited(neighbor.X, neighbor.Y)
+			if meet != nil && neighbor.G+meet.G < best {
+				best = neighbor.G + meet.G
+				meetForward, meetBackward = neighbor, meet
+				if reverse {
+					meetForward, meetBackward = meet, neighbor
+				}
+			}
+		}
+	}
+	if meetForward == nil {
+		return nil, ErrNoPath
+	}
+	// 正向部分：起点到相遇节点
+	path := newPath(meetForward, len(forward.closeList)+len(backward.closeList))
+	// 反向部分：相遇节点到终点，沿反向搜索的父节点即为正向顺序
+	for node := meetBackward.Parent; node != nil; node = node.Parent {
+		path.Points = append(path.Points, Point{X: node.X, Y: node.Y})
+	}
+	path.Cost = best
+	e.mark(path)
+	return path, nil
+}
+
+// 本轮搜索中已访问（开放或关闭）的节点，未访问时返回nil
+func (r *Searcher) visited(x, y int) *Node {
+	node := &r.nodes[x*r.astar.grid.Rows+y]
+	if node.search != r.search || node.State == NODE_STATE_NORMAL {
+		return nil
+	}
+	return node
+}
@@ -0,0 +1,39 @@
+package astar
+
+import (
+	"math/rand"
+	"testing"
+)
+
+// 随机地形上双向搜索的路径成本与FindPath相同，每一步都合法
+func TestFindPathBidirectional(t *testing.T) {
+	rnd := rand.New(rand.NewSource(15))
+	for m := 0; m < 4; m++ {
+		r, err := NewAStar(randomMap(rnd, 30, 30, 0.2, m%2 == 1), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, movement := range []int{MOVEMENT_FOUR, MOVEMENT_EIGHT, MOVEMENT_EIGHT_NO_CORNER_CUT, MOVEMENT_EIGHT_NO_OBSTACLE} {
+			r.Movement = movement
+			for i := 0; i < 100; i++ {
+				start, end := randomWalkable(rnd, r), randomWalkable(rnd, r)
+				want, wantErr := r.FindPath(&Node{X: start.X, Y: start.Y}, &Node{X: end.X, Y: end.Y})
+				path, err := r.FindPathBidirectional(&Node{X: start.X, Y: start.Y}, &Node{X: end.X, Y: end.Y})
+				if (err == nil) != (wantErr == nil) {
+					t.Fatalf("movement %d %v -> %v: %v, FindPath %v", movement, start, end, err, wantErr)
+				}
+				if err != nil {
+					continue
+				}
+				if path.Cost != want.Cost {
+					t.Fatalf("movement %d %v -> %v: cost %d, FindPath %d", movement, start, end, path.Cost, want.Cost)
+				}
+				checkPath(t, r, path, start, end)
+			}
+		}
+	}
+}
+
+func TestFindPathBidirectionalResolve(t *testing.T) {
+	checkResolve(t, (*AStar).FindPathBidirectional)
+}
@@ -0,0 +1,235 @@
+package astar
+
+import (
+	"container/heap"
+	"fmt"
+)
+
+/*
+基于冲突的搜索（CBS）
+底层为每个单位单独做时空A*，上层维护约束树：
+取出总成本最低的节点，找到路径中最早的冲突（同一时刻占用同一格子、交换位置或斜向交叉），
+分成两个子节点，分别禁止冲突中的一个单位在该时刻占用该格子（或做该次移动），
+只重新规划被约束的单位，第一个没有冲突的节点即为总成本最低的解
+*/
+
+// 约束树最多展开的节点数默认值
+const cbsMaxNodes = 1000
+
+// 单位的约束：不能在t时刻位于p，edge为true时不能在t时刻从p移动到next
+type cbsConstraint struct {
+	p    Point
+	next Point
+	t    int
+	edge bool
+}
+
+// 约束树节点
+type cbsNode struct {
+	// 每个单位的约束，子节点只复制被修改的单位
+	constraints [][]cbsConstraint
+	paths       []*Path
+	cost        int
+	// 冲突数量，总成本相同时冲突少的优先
+	conflicts int
+}
+
+// 两个单位的冲突
+type cbsConflict struct {
+	a, b int
+	t    int
+	// 顶点冲突时两个单位都在p，移动冲突时a从p移动到next，b从bp移动到bnext
+	p     Point
+	next  Point
+	bp    Point
+	bnext Point
+	edge  bool
+}
+
+func (r *AStar) findPathsCBS(agents []Agent, fields []*FlowField, opts CooperativeOptions) ([]*Path, error) {
+	maxNodes := opts.MaxNodes
+	if maxNodes <= 0 {
+		maxNodes = cbsMaxNodes
+	}
+	root := &cbsNode{
+		constraints: make([][]cbsConstraint, len(agents)),
+		paths:       make([]*Path, len(agents)),
+	}
+	for i := range agents {
+		path := r.searchConstrained(agents[i], fields[i], nil, opts.MaxTime)
+		if path == nil {
+			return nil, fmt.Errorf("agent %d: %w", i, ErrNoPath)
+		}
+		root.paths[i] = path
+		root.cost += path.Cost
+	}
+	root.conflicts = countConflicts(root.paths)
+	queue := &cbsQueue{root}
+	expanded := 0
+	for queue.Len() > 0 {
+		node := heap.Pop(queue).(*cbsNode)
+		conflict, ok := firstConflict(node.paths)
+		if !ok {
+			return node.paths, nil
+		}
+		if expanded++; expanded > maxNodes {
+			return nil, fmt.Errorf("%w: %d constraint tree nodes expanded", ErrBudget, maxNodes)
+		}
+		for _, child := range conflict.split() {
+			agent := child.agent
+			next := &cbsNode{
+				constraints: append([][]cbsConstraint(nil), node.constraints...),
+				paths:       append([]*Path(nil), node.paths...),
+			}
+			next.constraints[agent] = append(append([]cbsConstraint(nil), node.constraints[agent]...), child.constraint)
+			path := r.searchConstrained(agents[agent], fields[agent], next.constraints[agent], opts.MaxTime)
+			if path == nil {
+				continue
+			}
+			next.paths[agent] = path
+			next.cost = node.cost - node.paths[agent].Cost + path.Cost
+			next.conflicts = countConflicts(next.paths)
+			heap.Push(queue, next)
+		}
+	}
+	return nil, ErrNoPath
+}
+
+// 按约束做时空A*
+func (r *AStar) searchConstrained(agent Agent, field *FlowField, constraints []cbsConstraint, limit int) *Path {
+	table := newReservations()
+	for _, c := range constraints {
+		if c.edge {
+			table.blockEdge(c.p, c.next, c.t)
+		} else {
+			table.blockVertex(c.p, c.t)
+		}
+	}
+	return r.searchSpaceTime(spaceTimeQuery{
+		start: agent.Start,
+		end:   agent.End,
+		limit: limit,
+		field: field,
+		table: table,
+	})
+}
+
+// 冲突拆分出的约束
+type cbsBranch struct {
+	agent      int
+	constraint cbsConstraint
+}
+
+func (c cbsConflict) split() []cbsBranch {
+	if !c.edge {
+		return []cbsBranch{
+			{agent: c.a, constraint: cbsConstraint{p: c.p, t: c.t}},
+			{agent: c.b, constraint: cbsConstraint{p: c.p, t: c.t}},
+		}
+	}
+	return []cbsBranch{
+		{agent: c.a, constraint: cbsConstraint{p: c.p, next: c.next, t: c.t, edge: true}},
+		{agent: c.b, constraint: cbsConstraint{p: c.bp, next: c.bnext, t: c.t, edge: true}},
+	}
+}
+
+// 单位在t时刻的位置，到达终点后停在终点
+func positionAt(path *Path, t int) Point {
+	if t >= len(path.Points) {
+		return path.Points[len(path.Points)-1]
+	}
+	return path.Points[t]
+}
+
+// 最早的冲突
+func firstConflict(paths []*Path) (cbsConflict, bool) {
+	length := 0
+	for _, path := range paths {
+		length = max(length, len(path.Points))
+	}
+	for t := 0; t < length; t++ {
+		for a := range paths {
+			for b := a + 1; b < len(paths); b++ {
+				if c, ok := conflictAt(paths, a, b, t); ok {
+					return c, true
+				}
+			}
+		}
+	}
+	return cbsConflict{}, false
+}
+
+// 冲突总数
+func countConflicts(paths []*Path) int {
+	length := 0
+	for _, path := range paths {
+		length = max(length, len(path.Points))
+	}
+	n := 0
+	for t := 0; t < length; t++ {
+		for a := range paths {
+			for b := a + 1; b < len(paths); b++ {
+				if _, ok := conflictAt(paths, a, b, t); ok {
+					n++
+				}
+			}
+		}
+	}
+	return n
+}
+
+// 单位a、b在t时刻的顶点冲突，或从t-1到t时刻的交换、斜向交叉冲突
+func conflictAt(paths []*Path, a, b, t int) (cbsConflict, bool) {
+	pa, pb := positionAt(paths[a], t), positionAt(paths[b], t)
+	if pa == pb {
+		return cbsConflict{a: a, b: b, t: t, p: pa}, true
+	}
+	if t == 0 {
+		return cbsConflict{}, false
+	}
+	prevA, prevB := positionAt(paths[a], t-1), positionAt(paths[b], t-1)
+	if prevA == pb && prevB == pa || crossing(prevA, pa, prevB, pb) {
+		return cbsConflict{a: a, b: b, t: t - 1, p: prevA, next: pa, bp: prevB, bnext: pb, edge: true}, true
+	}
+	return cbsConflict{}, false
+}
+
+// a从p斜向移动到next，b同时沿同一个2x2方块的另一条对角线移动
+func crossing(p, next, bp, bnext Point) bool {
+	if p.X == next.X || p.Y == next.Y {
+		return false
+	}
+	return bp == Point{X: next.X, Y: p.Y} && bnext == Point{X: p.X, Y: next.Y} ||
+		bp == Point{X: p.X, Y: next.Y} && bnext == Point{X: next.X, Y: p.Y}
+}
+
+// 约束树的开放列表
+type cbsQueue []*cbsNode
+
+func (q cbsQueue) Len() int {
+	return len(q)
+}
+
+func (q cbsQueue) Less(i, j int) bool {
+	if q[i].cost != q[j].cost {
+		return q[i].cost < q[j].cost
+	}
+	return q[i].conflicts < q[j].conflicts
+}
+
+func (q cbsQueue) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+}
+
+func (q *cbsQueue) Push(x any) {
+	*q = append(*q, x.(*cbsNode))
+}
+
+func (q *cbsQueue) Pop() any {
+	s := *q
+	n := len(s) - 1
+	node := s[n]
+	s[n] = nil
+	*q = s[:n]
+	return node
+}
@@ -0,0 +1,301 @@
+package astar
+
+import "sync"
+
+/*
+连通区域
+用并查集标记可以互相到达的格子，起止点不在同一区域时不必搜索，直接返回ErrNoPath
+对角移动需要两侧可行时，两个格子能经过对角到达就一定能经过侧边到达，
+所以只有MOVEMENT_EIGHT按8方向连通，其他移动方式都按4方向连通
+格子变化先记录下来，查询时再处理：
+  变为可行：分配新的编号，与相邻的可行格子合并
+  变为障碍：周围的可行格子在3x3范围内仍然互相连通时区域不会被分开，否则下次查询时重新标记
+  逐个处理时按已有编号的格子判断，一次处理多个变化时还没处理到的格子仍按原来的状态参与判断
+编号只增不减，编号数超过格子数的COMPONENTS_REBUILD_FACTOR倍时重新标记
+占据size*size格子的单位按左上角能否站下（Fits）划分区域，连通规则与单格相同，
+一个格子的变化影响左上方size*size范围内格子能否站下
+区域是最新的时查询只持有读锁，不压缩路径，按秩合并保证查找链不长
+地图释放写锁后才通知订阅者，查询时可能还有变化没有收到：
+  按地图版本判断，收到的变化不全时按当前地图重新标记，之后收到的旧变化忽略
+*/
+
+// 编号数超过格子数的倍数时重新标记
+const COMPONENTS_REBUILD_FACTOR = 2
+
+type components struct {
+	mu   sync.RWMutex
+	grid *Grid
+	// 单位大小
+	size int
+	// 是否按8方向连通，与移动方式不符时重新标记
+	eight bool
+	// 格子在并查集中的编号，障碍为-1，按x*Rows+y存放
+	ids    []int
+	parent []int
+	rank   []int
+	// 等待处理的格子变化
+	pending []Point
+	// 编号对应的地图版本，以及之后收到的变化数量
+	version  int
+	received int
+	// 为true时下次查询重新标记
+	dirty bool
+}
+
+func newComponents(grid *Grid, size int) *components {
+	c := &components{
+		grid:  grid,
+		size:  max(size, 1),
+		dirty: true,
+	}
+	grid.Subscribe(func(change Change) {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		// 重新标记时已经包含了这个变化
+		if change.Version <= c.version {
+			return
+		}
+		c.received++
+		if change.WalkableChanged() {
+			c.pending = append(c.pending, Point{X: change.X, Y: change.Y})
+		}
+	})
+	return c
+}
+
+// 两个能站下的格子是否连通，调用方持有地图的读锁
+func (c *components) connected(movement int, a, b Point) bool {
+	c.mu.RLock()
+	if c.current(movement) {
+		defer c.mu.RUnlock()
+		return c.same(a, b, c.root)
+	}
+	c.mu.RUnlock()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.update(movement)
+	return c.same(a, b, c.find)
+}
+
+// 编号对应当前地图，区域与移动方式相符，调用方持有地图的读锁
+func (c *components) current(movement int) bool {
+	return !c.dirty && c.received == 0 && c.version == c.grid.Version() && c.eight == (movement == MOVEMENT_EIGHT)
+}
+
+func (c *components) same(a, b Point, find func(id int) int) bool {
+	ia, ib := c.ids[c.id(a)], c.ids[c.id(b)]
+	if ia < 0 || ib < 0 {
+		return false
+	}
+	return find(ia) == find(ib)
+}
+
+// 处理等待的格子变化，需要时重新标记，调用方持有地图的读锁
+func (c *components) update(movement int) {
+	if eight := movement == MOVEMENT_EIGHT; eight != c.eight {
+		c.eight = eight
+		c.dirty = true
+	}
+	// 还有变化没有收到
+	if c.version+c.received != c.grid.Version() {
+		c.dirty = true
+	}
+	if !c.dirty {
+	pending:
+		for _, p := range c.pending {
+			// 变化的格子影响左上方size*size范围内格子能否站下
+			for x := p.X - c.size + 1; x <= p.X; x++ {
+				for y := p.Y - c.size + 1; y <= p.Y; y++ {
+					if !c.grid.InBounds(x, y) {
+						continue
+					}
+					q := Point{X: x, Y: y}
+					fits, labeled := c.fits(x, y), c.ids[c.id(q)] >= 0
+					if fits && !labeled {
+						c.add(q)
+					} else if !fits && labeled {
+						c.ids[c.id(q)] = -1
+						if !c.locallyConnected(q) {
+							c.dirty = true
+							break pending
+						}
+					}
+				}
+			}
+		}
+	}
+	c.pending = c.pending[:0]
+	c.version, c.received = c.grid.Version(), 0
+	if len(c.parent) > COMPONENTS_REBUILD_FACTOR*c.grid.Rows*c.grid.Cols {
+		c.dirty = true
+	}
+	if c.dirty {
+		c.build()
+	}
+}
+
+// 单位能否站在x,y
+func (c *components) fits(x, y int) bool {
+	return c.grid.Fits(x, y, c.size)
+}
+
+// 重新标记所有格子
+func (c *components) build() {
+	g := c.grid
+	c.ids = make([]int, g.Rows*g.Cols)
+	c.parent = c.parent[:0]
+	c.rank = c.rank[:0]
+	for x := 0; x < g.Cols; x++ {
+		for y := 0; y < g.Rows; y++ {
+			c.ids[c.id(Point{X: x, Y: y})] = -1
+		}
+	}
+	for x := 0; x < g.Cols; x++ {
+		for y := 0; y < g.Rows; y++ {
+			if c.fits(x, y) {
+				c.add(Point{X: x, Y: y})
+			}
+		}
+	}
+	c.dirty = false
+}
+
+// 能站下的格子分配新的编号，与相邻的已有编号的格子合并
+func (c *components) add(p Point) {
+	id := len(c.parent)
+	c.parent = append(c.parent, id)
+	c.rank = append(c.rank, 0)
+	c.ids[c.id(p)] = id
+	for _, v := range c.neighborPos() {
+		if other := c.label(p.X+v[0], p.Y+v[1]); other >= 0 {
+			c.union(id, other)
+		}
+	}
+}
+
+// 格子的编号，越界或没有编号时返回-1
+func (c *components) label(x, y int) int {
+	if !c.grid.InBounds(x, y) {
+		return -1
+	}
+	return c.ids[c.id(Point{X: x, Y: y})]
+}
+
+// 格子去掉编号后，与它相邻的有编号的格子在3x3范围内是否仍然互相连通
+func (c *components) locallyConnected(p Point) bool {
+	// 3x3范围内除中心外有编号的格子
+	inside := func(x, y int) bool {
+		return abs(x-p.X) <= 1 && abs(y-p.Y) <= 1 && (x != p.X || y != p.Y) && c.label(x, y) >= 0
+	}
+	neighbors := make([]Point, 0, 8)
+	for _, v := range c.neighborPos() {
+		if inside(p.X+v[0], p.Y+v[1]) {
+			neighbors = append(neighbors, Point{X: p.X + v[0], Y: p.Y + v[1]})
+		}
+	}
+	if len(neighbors) <= 1 {
+		return true
+	}
+	seen := map[Point]bool{neighbors[0]: true}
+	queue := []Point{neighbors[0]}
+	for len(queue) > 0 {
+		q := queue[0]
+		queue = queue[1:]
+		for _, v := range c.neighborPos() {
+			next := Point{X: q.X + v[0], Y: q.Y + v[1]}
+			if !seen[next] && inside(next.X, next.Y) {
+				seen[next] = true
+				queue = append(queue, next)
+			}
+		}
+	}
+	for _, q := range neighbors {
+		if !seen[q] {
+			return false
+		}
+	}
+	return true
+}
+
+func (c *components) neighborPos() [][]int {
+	if c.eight {
+		return allPos
+	}
+	return straightPos
+}
+
+// 查找根并压缩路径，调用方持有写锁
+func (c *components) find(id int) int {
+	for c.parent[id] != id {
+		c.parent[id] = c.parent[c.parent[id]]
+		id = c.parent[id]
+	}
+	return id
+}
+
+// 查找根，不修改并查集，持有读锁即可
+func (c *components) root(id int) int {
+	for c.parent[id] != id {
+		id = c.parent[id]
+	}
+	return id
+}
+
+// 按秩合并
+func (c *components) union(a, b int) {
+	a, b = c.find(a), c.find(b)
+	if a == b {
+		return
+	}
+	if c.rank[a] > c.rank[b] {
+		a, b = b, a
+	}
+	c.parent[a] = b
+	if c.rank[a] == c.rank[b] {
+		c.rank[b]++
+	}
+}
+
+func (c *components) id(p Point) int {
+	return p.X*c.grid.Rows + p.Y
+}
+
+// 与from连通且满足accept的格子中离to最近的一个，调用方持有地图的读锁
+// 按到to的切比雪夫距离逐圈查找，直到圈的距离超过已找到的最近距离
+func (c *components) nearest(movement int, from, to Point, accept func(x, y int) bool) (Point, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.update(movement)
+	g := c.grid
+	if c.ids[c.id(from)] < 0 {
+		return Point{}, false
+	}
+	root := c.find(c.ids[c.id(from)])
+	var best Point
+	bestDist := -1
+	for radius := 1; radius < max(g.Rows, g.Cols); radius++ {
+		if bestDist >= 0 && radius*radius > bestDist {
+			break
+		}
+		for x := to.X - radius; x <= to.X+radius; x++ {
+			for y := to.Y - radius; y <= to.Y+radius; y++ {
+				// 只检查圈上的格子
+				if abs(x-to.X) != radius && abs(y-to.Y) != radius {
+					continue
+				}
+				if !g.InBounds(x, y) || !accept(x, y) {
+					continue
+				}
+				id := c.ids[c.id(Point{X: x, Y: y})]
+				if id < 0 || c.find(id) != root {
+					continue
+				}
+				dx, dy := x-to.X, y-to.Y
+				if dist := dx*dx + dy*dy; bestDist < 0 || dist < bestDist {
+					best, bestDist = Point{X: x, Y: y}, dist
+				}
+			}
+		}
+	}
+	return best, bestDist >= 0
+}
@@ -0,0 +1,178 @@
+package astar
+
+import (
+	"errors"
+	"math/rand"
+	"sync"
+	"testing"
+)
+
+// 每次修改地图后，Connected与A*能否找到路径一致
+// 终点不可达时返回ErrNoPath，设置Redirect后改为寻路到起点所在区域中的格子
+func TestConnectedFollowsEdits(t *testing.T) {
+	rnd := rand.New(rand.NewSource(22))
+	r, err := NewAStar(randomMap(rnd, 20, 20, 0.3, false), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	movements := []int{MOVEMENT_FOUR, MOVEMENT_EIGHT, MOVEMENT_EIGHT_NO_CORNER_CUT, MOVEMENT_EIGHT_NO_OBSTACLE}
+	for i := 0; i < 300; i++ {
+		if err := r.Grid().SetWalkable(rnd.Intn(20), rnd.Intn(20), rnd.Intn(2) == 0); err != nil {
+			t.Fatal(err)
+		}
+		r.Movement = movements[rnd.Intn(len(movements))]
+		start, end := randomWalkable(rnd, r), randomWalkable(rnd, r)
+		r.Redirect = false
+		path, err := r.FindPath(&Node{X: start.X, Y: start.Y}, &Node{X: end.X, Y: end.Y})
+		if r.Connected(start, end) != (err == nil) {
+			t.Fatalf("edit %d movement %d: %v -> %v connected %v, err %v", i, r.Movement, start, end, r.Connected(start, end), err)
+		}
+		if err == nil {
+			checkPath(t, r, path, start, end)
+			continue
+		}
+		if !errors.Is(err, ErrNoPath) {
+			t.Fatalf("edit %d: %v", i, err)
+		}
+		r.Redirect = true
+		path, err = r.FindPath(&Node{X: start.X, Y: start.Y}, &Node{X: end.X, Y: end.Y})
+		if err != nil {
+			t.Fatalf("edit %d redirect %v -> %v: %v", i, start, end, err)
+		}
+		last := path.Points[len(path.Points)-1]
+		if !path.Redirected || !r.Connected(start, last) {
+			t.Fatalf("edit %d redirect %v -> %v: ends at %v, redirected %v", i, start, end, last, path.Redirected)
+		}
+		checkPath(t, r, path, start, last)
+	}
+}
+
+// 按移动方式从start广度优先搜索，返回size*size的单位能到达的格子
+func reachable(g *Grid, movement, size int, start Point) map[Point]bool {
+	g.RLock()
+	defer g.RUnlock()
+	seen := map[Point]bool{}
+	if !g.Fits(start.X, start.Y, size) {
+		return seen
+	}
+	seen[start] = true
+	queue := []Point{start}
+	for len(queue) > 0 {
+		p := queue[0]
+		queue = queue[1:]
+		for _, v := range allPos {
+			next := Point{X: p.X + v[0], Y: p.Y + v[1]}
+			if !seen[next] && g.CanMoveSize(movement, size, p.X, p.Y, v[0], v[1]) {
+				seen[next] = true
+				queue = append(queue, next)
+			}
+		}
+	}
+	return seen
+}
+
+// 随机修改地图后，各种单位大小和移动方式下的连通性与广度优先搜索一致，编号数不会无限增长
+func TestComponentsFollowEdits(t *testing.T) {
+	rnd := rand.New(rand.NewSource(5))
+	r, err := NewAStar(randomMap(rnd, 20, 20, 0.25, false), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	movements := []int{MOVEMENT_FOUR, MOVEMENT_EIGHT, MOVEMENT_EIGHT_NO_CORNER_CUT, MOVEMENT_EIGHT_NO_OBSTACLE}
+	for i := 0; i < 300; i++ {
+		r.Grid().SetWalkable(rnd.Intn(20), rnd.Intn(20), rnd.Intn(2) == 0)
+		movement, size := movements[rnd.Intn(len(movements))], 1+rnd.Intn(3)
+		c := r.componentsFor(size)
+		start := Point{X: rnd.Intn(20), Y: rnd.Intn(20)}
+		want := reachable(r.Grid(), movement, size, start)
+		r.Grid().RLock()
+		for x := 0; x < 20; x++ {
+			for y := 0; y < 20; y++ {
+				end := Point{X: x, Y: y}
+				if got := c.connected(movement, start, end); got != want[end] {
+					t.Fatalf("edit %d size %d movement %d: %v -> %v connected %v, want %v", i, size, movement, start, end, got, want[end])
+				}
+			}
+		}
+		r.Grid().RUnlock()
+	}
+	for size, c := range r.components {
+		if len(c.parent) > COMPONENTS_REBUILD_FACTOR*20*20 {
+			t.Fatalf("size %d: %d ids for %d cells", size, len(c.parent), 20*20)
+		}
+	}
+}
+
+// 大单位的终点不可达时改为离终点最近、大单位能到达的格子
+func TestFindPathSizeRedirect(t *testing.T) {
+	rnd := rand.New(rand.NewSource(6))
+	r, err := NewAStar(randomMap(rnd, 20, 20, 0.25, false), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Redirect = true
+	for i := 0; i < 200; i++ {
+		start, end := randomWalkable(rnd, r), randomWalkable(rnd, r)
+		if !r.Grid().Fits(start.X, start.Y, 2) || !r.Grid().Fits(end.X, end.Y, 2) {
+			continue
+		}
+		path, err := r.FindPathSize(&Node{X: start.X, Y: start.Y}, &Node{X: end.X, Y: end.Y}, 2)
+		if errors.Is(err, ErrNoPath) {
+			t.Fatalf("%v -> %v: %v", start, end, err)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		last := path.Points[len(path.Points)-1]
+		if !reachable(r.Grid(), r.Movement, 2, start)[last] {
+			t.Fatalf("%v -> %v: redirected to %v, which a size 2 unit cannot reach", start, end, last)
+		}
+	}
+}
+
+// 多个goroutine同时寻路和修改地图，订阅者还没收到变化时区域也不会过期：
+// 不会越界，起止点连通时不会返回ErrNoPath
+func TestComponentsConcurrentEdits(t *testing.T) {
+	r, err := NewAStar(randomMap(rand.New(rand.NewSource(7)), 6, 6, 0.3, false), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Redirect = true
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func(seed int64) {
+			defer wg.Done()
+			rnd := rand.New(rand.NewSource(seed))
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				r.Grid().SetWalkable(rnd.Intn(6), rnd.Intn(6), rnd.Intn(2) == 0)
+			}
+		}(int64(i))
+	}
+	var searchers sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		searchers.Add(1)
+		go func(seed int64) {
+			defer searchers.Done()
+			rnd := rand.New(rand.NewSource(100 + seed))
+			for k := 0; k < 2000; k++ {
+				start, end := Point{X: rnd.Intn(6), Y: rnd.Intn(6)}, Point{X: rnd.Intn(6), Y: rnd.Intn(6)}
+				_, err := r.FindPath(&Node{X: start.X, Y: start.Y}, &Node{X: end.X, Y: end.Y})
+				// 开启Redirect时，起点可行就一定能到达某个格子
+				if errors.Is(err, ErrNoPath) {
+					t.Errorf("%v -> %v: %v", start, end, err)
+					return
+				}
+			}
+		}(int64(i))
+	}
+	searchers.Wait()
+	close(stop)
+	wg.Wait()
+}
@@ -0,0 +1,414 @@
+package astar
+
+import (
+	"container/heap"
+	"fmt"
+	"math"
+)
+
+/*
+多单位协同寻路
+在时空网格上搜索，状态为(x,y,t)，每一步可以移动到相邻格子或原地等待，
+已规划单位占用的时空格子记录在预约表中，后规划的单位避开它们：
+  不能在同一时刻占用同一格子，不能在同一时刻交换位置，
+  不能同时沿同一个2x2方块的两条对角线斜向交叉
+单位到达终点后停在终点，停下后的等待不计成本；在终点等待后又离开终点时，这些等待照常计入成本
+HCA*：按顺序为每个单位规划完整路径并预约，到达终点后一直占用终点
+WHCA*：每轮只在窗口内规划和预约，所有单位前进半个窗口后重新规划，每轮轮换优先级
+还没有规划的单位占用所在的格子到下一时刻，先规划的单位不能在第一步挤进去
+启发值是忽略其他单位时到终点的真实成本（从终点反向计算的流场）
+*/
+
+// 单位的起点和终点
+type Agent struct {
+	Start Point `json:"start"`
+	End   Point `json:"end"`
+}
+
+// 协同寻路参数
+type CooperativeOptions struct {
+	// 窗口大小，0表示按顺序规划完整路径（HCA*）
+	Window int
+	// 最多的时间步，默认为最长的单独路径步数的两倍加单位数量
+	MaxTime int
+	// 使用基于冲突的搜索（CBS），得到总成本最低的路径，适合单位较少的情况
+	CBS bool
+	// CBS最多展开的约束树节点数，默认1000
+	MaxNodes int
+}
+
+// 为一组单位规划互不碰撞的路径
+// 路径的第t个点是单位在t时刻的位置，等待时重复同一个点，到达终点后不再列出
+func (r *AStar) FindPathsCooperative(agents []Agent, opts CooperativeOptions) ([]*Path, error) {
+	r.grid.RLock()
+	defer r.grid.RUnlock()
+	for i, agent := range agents {
+		start := &Node{X: agent.Start.X, Y: agent.Start.Y}
+		end := &Node{X: agent.End.X, Y: agent.End.Y}
+		if err := checkEndpoints(r.grid, start, end); err != nil {
+			return nil, fmt.Errorf("agent %d: %w", i, err)
+		}
+		for j := 0; j < i; j++ {
+			if agents[j].Start == agent.Start {
+				return nil, fmt.Errorf("agents %d and %d start at the same point: %w", j, i, ErrNoPath)
+			}
+			if agents[j].End == agent.End {
+				return nil, fmt.Errorf("agents %d and %d end at the same point: %w", j, i, ErrNoPath)
+			}
+		}
+	}
+	// 每个单位到终点的真实成本，作为启发值
+	fields := make([]*FlowField, len(agents))
+	steps := 0
+	for i, agent := range agents {
+		fields[i] = &FlowField{
+			astar: r,
+			goal:  agent.End,
+			costs: make([]int, r.grid.Rows*r.grid.Cols),
+			dirs:  make([]Point, r.grid.Rows*r.grid.Cols),
+		}
+		fields[i].build()
+		n, ok := fields[i].steps(agent.Start)
+		if !ok {
+			return nil, fmt.Errorf("agent %d: %w", i, ErrNoPath)
+		}
+		steps = max(steps, n)
+	}
+	if opts.MaxTime <= 0 {
+		opts.MaxTime = steps*2 + len(agents)
+	}
+	if opts.CBS {
+		return r.findPathsCBS(agents, fields, opts)
+	}
+	if opts.Window <= 0 {
+		return r.findPathsHCA(agents, fields, opts)
+	}
+	return r.findPathsWHCA(agents, fields, opts)
+}
+
+// 按顺序规划完整路径
+func (r *AStar) findPathsHCA(agents []Agent, fields []*FlowField, opts CooperativeOptions) ([]*Path, error) {
+	table := newReservations()
+	for _, agent := range agents {
+		table.hold(agent.Start, 1)
+	}
+	paths := make([]*Path, len(agents))
+	for i, agent := range agents {
+		table.release(agent.Start)
+		path := r.searchSpaceTime(spaceTimeQuery{
+			start: agent.Start,
+			end:   agent.End,
+			limit: opts.MaxTime,
+			field: fields[i],
+			table: table,
+		})
+		if path == nil {
+			return nil, fmt.Errorf("agent %d: %w", i, ErrNoPath)
+		}
+		table.reserve(path.Points, 0)
+		table.park(agent.End, len(path.Points)-1)
+		paths[i] = path
+	}
+	return paths, nil
+}
+
+// 按窗口分轮规划
+func (r *AStar) findPathsWHCA(agents []Agent, fields []*FlowField, opts CooperativeOptions) ([]*Path, error) {
+	paths := make([]*Path, len(agents))
+	for i, agent := range agents {
+		paths[i] = &Path{Points: []Point{agent.Start}}
+	}
+	// 每轮前进的步数
+	advance := max(opts.Window/2, 1)
+	for t, round := 0, 0; ; t, round = t+advance, round+1 {
+		done := true
+		for i, agent := range agents {
+			if paths[i].Points[t] != agent.End {
+				done = false
+			}
+		}
+		if done {
+			break
+		}
+		if t >= opts.MaxTime {
+			return nil, fmt.Errorf("%w: agents did not reach their goals in %d steps", ErrBudget, opts.MaxTime)
+		}
+		table := newReservations()
+		for i := range agents {
+			table.hold(paths[i].Points[t], t+1)
+		}
+		plans := make([]*Path, len(agents))
+		for k := range agents {
+			// 每轮轮换优先级
+			i := (k + round) % len(agents)
+			table.release(paths[i].Points[t])
+			plan := r.searchSpaceTime(spaceTimeQuery{
+				start:  paths[i].Points[t],
+				end:    agents[i].End,
+				t0:     t,
+				limit:  t + opts.Window,
+				window: true,
+				field:  fields[i],
+				table:  table,
+			})
+			if plan == nil {
+				return nil, fmt.Errorf("agent %d at step %d: %w", i, t, ErrNoPath)
+			}
+			table.reserve(plan.Points, t)
+			plans[i] = plan
+		}
+		for i, plan := range plans {
+			paths[i].Points = append(paths[i].Points, plan.Points[1:advance+1]...)
+			paths[i].Expanded += plan.Expanded
+		}
+	}
+	for i, agent := range agents {
+		paths[i].Points = trimWaits(paths[i].Points, agent.End)
+		paths[i].Cost = r.spaceTimeCost(paths[i].Points)
+	}
+	return paths, nil
+}
+
+// 去掉到达终点后的等待
+func trimWaits(points []Point, end Point) []Point {
+	n := len(points)
+	for n > 1 && points[n-1] == end && points[n-2] == end {
+		n--
+	}
+	return points[:n]
+}
+
+// 去掉到达终点后的等待的时空路径的成本，移动按地形计算，等待按直线移动计算
+func (r *AStar) spaceTimeCost(points []Point) int {
+	cost := 0
+	for i := 1; i < len(points); i++ {
+		cost += r.spaceTimeStep(points[i-1], points[i])
+	}
+	return cost
+}
+
+func (r *AStar) spaceTimeStep(p, next Point) int {
+	if p != next {
+		return r.grid.StepCost(p.X, p.Y, next.X, next.Y)
+	}
+	return COST_STRAIGHT
+}
+
+// 从格子沿流场方向到终点的步数
+func (f *FlowField) steps(p Point) (int, bool) {
+	id := f.id(p.X, p.Y)
+	if f.costs[id] == math.MaxInt {
+		return 0, false
+	}
+	n := 0
+	for f.costs[id] != 0 {
+		p = Point{X: p.X + f.dirs[id].X, Y: p.Y + f.dirs[id].Y}
+		id = f.id(p.X, p.Y)
+		n++
+	}
+	return n, true
+}
+
+// 预约表，也用作CBS中单个单位的约束
+type reservations struct {
+	// 不能在t时刻位于x,y
+	vertices map[[3]int]bool
+	// 不能在t时刻从x,y移动到nx,ny
+	edges map[[5]int]bool
+	// 从某时刻起一直被占用的格子（停在终点的单位）
+	parked map[Point]int
+	// 格子最后一次被占用的时刻，用于判断能否停在终点
+	last map[Point]int
+	// 还没有规划的单位所在的格子，到该时刻为止一直被占用
+	held map[Point]int
+}
+
+func newReservations() *reservations {
+	return &reservations{
+		vertices: make(map[[3]int]bool),
+		edges:    make(map[[5]int]bool),
+		parked:   make(map[Point]int),
+		last:     make(map[Point]int),
+		held:     make(map[Point]int),
+	}
+}
+
+// 预约其他单位从t0时刻开始的路径：占用每个时空格子，禁止反向交换位置，
+// 斜向移动时禁止同一时刻沿另一条对角线交叉
+func (t *reservations) reserve(points []Point, t0 int) {
+	for i, p := range points {
+		t.blockVertex(p, t0+i)
+		if i == 0 || points[i-1] == p {
+			continue
+		}
+		prev := points[i-1]
+		t.blockEdge(p, prev, t0+i-1)
+		if p.X != prev.X && p.Y != prev.Y {
+			a, b := Point{X: prev.X, Y: p.Y}, Point{X: p.X, Y: prev.Y}
+			t.blockEdge(a, b, t0+i-1)
+			t.blockEdge(b, a, t0+i-1)
+		}
+	}
+}
+
+// 还没有规划的单位占用p直到until时刻
+func (t *reservations) hold(p Point, until int) {
+	t.held[p] = until
+}
+
+// 单位开始规划，不再占用自己所在的格子
+func (t *reservations) release(p Point) {
+	delete(t.held, p)
+}
+
+// 单位从t时刻起停在p
+func (t *reservations) park(p Point, from int) {
+	t.parked[p] = from
+}
+
+func (t *reservations) blockVertex(p Point, at int) {
+	t.vertices[[3]int{p.X, p.Y, at}] = true
+	if last, ok := t.last[p]; !ok || at > last {
+		t.last[p] = at
+	}
+}
+
+func (t *reservations) blockEdge(p, next Point, at int) {
+	t.edges[[5]int{p.X, p.Y, next.X, next.Y, at}] = true
+}
+
+// 能否在t时刻从p移动（或等待）到next
+func (t *reservations) allowed(p, next Point, at int) bool {
+	if t.vertices[[3]int{next.X, next.Y, at + 1}] {
+		return false
+	}
+	if from, ok := t.parked[next]; ok && from <= at+1 {
+		return false
+	}
+	if until, ok := t.held[next]; ok && at+1 <= until {
+		return false
+	}
+	return !t.edges[[5]int{p.X, p.Y, next.X, next.Y, at}]
+}
+
+// 能否从t时刻起一直停在p
+func (t *reservations) canStay(p Point, at int) bool {
+	if _, ok := t.parked[p]; ok {
+		return false
+	}
+	last, ok := t.last[p]
+	return !ok || last <= at
+}
+
+// 时空搜索的参数
+type spaceTimeQuery struct {
+	start Point
+	end   Point
+	// 开始时刻和最晚时刻
+	t0    int
+	limit int
+	// 为true时到达最晚时刻即结束（WHCA*的窗口），否则必须能停在终点
+	window bool
+	field  *FlowField
+	table  *reservations
+}
+
+// 时空搜索的节点
+type spaceTimeNode struct {
+	Point
+	t int
+	// 不含在终点连续等待的成本，停在终点时就是路径的成本
+	g int
+	f int
+	// 在终点连续等待的步数，离开终点时计入成本
+	waits  int
+	parent *spaceTimeNode
+}
+
+// 时空A*，返回从t0时刻开始每个时刻的位置，找不到时返回nil
+func (r *AStar) searchSpaceTime(q spaceTimeQuery) *Path {
+	heuristic := func(p Point) int {
+		return q.field.costs[q.field.id(p.X, p.Y)]
+	}
+	queue := &spaceTimeQueue{}
+	heap.Push(queue, &spaceTimeNode{Point: q.start, t: q.t0, f: heuristic(q.start)})
+	// 在终点等待的步数不同时成本不同，按等待步数区分状态
+	closed := make(map[[4]int]bool)
+	moves := append([][]int{{0, 0}}, r.neighborPos()...)
+	expanded := 0
+	for queue.Len() > 0 {
+		node := heap.Pop(queue).(*spaceTimeNode)
+		key := [4]int{node.X, node.Y, node.t, node.waits}
+		if closed[key] {
+			continue
+		}
+		closed[key] = true
+		expanded++
+		if q.window && node.t >= q.limit || !q.window && node.Point == q.end && q.table.canStay(q.end, node.t) {
+			path := &Path{Cost: node.g, Expanded: expanded}
+			for ; node != nil; node = node.parent {
+				path.Points = append(path.Points, node.Point)
+			}
+			for i, j := 0, len(path.Points)-1; i < j; i, j = i+1, j-1 {
+				path.Points[i], path.Points[j] = path.Points[j], path.Points[i]
+			}
+			return path
+		}
+		if node.t >= q.limit {
+			continue
+		}
+		for _, v := range moves {
+			next := Point{X: node.X + v[0], Y: node.Y + v[1]}
+			if next != node.Point && !r.grid.CanMove(r.Movement, node.X, node.Y, v[0], v[1]) {
+				continue
+			}
+			h := heuristic(next)
+			if h == math.MaxInt || !q.table.allowed(node.Point, next, node.t) {
+				continue
+			}
+			// 在终点等待先记下步数，停在终点时不计成本，离开终点时一并计入
+			g, waits := node.g, 0
+			if next == node.Point && next == q.end {
+				waits = node.waits + 1
+			} else {
+				g += node.waits*COST_STRAIGHT + r.spaceTimeStep(node.Point, next)
+			}
+			if closed[[4]int{next.X, next.Y, node.t + 1, waits}] {
+				continue
+			}
+			heap.Push(queue, &spaceTimeNode{Point: next, t: node.t + 1, g: g, f: g + h, waits: waits, parent: node})
+		}
+	}
+	return nil
+}
+
+// 时空搜索的开放列表，F相同时时刻晚的优先
+type spaceTimeQueue []*spaceTimeNode
+
+func (q spaceTimeQueue) Len() int {
+	return len(q)
+}
+
+func (q spaceTimeQueue) Less(i, j int) bool {
+	if q[i].f != q[j].f {
+		return q[i].f < q[j].f
+	}
+	return q[i].t > q[j].t
+}
+
+func (q spaceTimeQueue) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+}
+
+func (q *spaceTimeQueue) Push(x any) {
+	*q = append(*q, x.(*spaceTimeNode))
+}
+
+func (q *spaceTimeQueue) Pop() any {
+	s := *q
+	n := len(s) - 1
+	node := s[n]
+	s[n] = nil
+	*q = s[:n]
+	return node
+}
@@ -0,0 +1,188 @@
+package astar
+
+import (
+	"math/rand"
+	"testing"
+)
+
+// 随机地图上为4个单位协同寻路，每种模式得到的路径起止点正确，
+// 每一步是等待或合法的移动，单位之间没有顶点冲突和交换冲突
+func TestFindPathsCooperative(t *testing.T) {
+	rnd := rand.New(rand.NewSource(20))
+	solved := make(map[string]int)
+	for round := 0; round < 30; round++ {
+		r, err := NewAStar(randomMap(rnd, 12, 12, 0.15, false), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r.Movement = MOVEMENT_FOUR
+		agents := make([]Agent, 0, 4)
+		used := make(map[Point]bool)
+		for len(agents) < 4 {
+			start, end := randomWalkable(rnd, r), randomWalkable(rnd, r)
+			if used[start] || used[end] || start == end {
+				continue
+			}
+			used[start], used[end] = true, true
+			agents = append(agents, Agent{Start: start, End: end})
+		}
+		for name, opts := range cooperativeModes {
+			paths, err := r.FindPathsCooperative(agents, opts)
+			if err != nil {
+				continue
+			}
+			solved[name]++
+			checkCooperative(t, r, agents, paths)
+		}
+	}
+	for name := range cooperativeModes {
+		if solved[name] == 0 {
+			t.Errorf("%s solved no round", name)
+		}
+	}
+}
+
+// 检查协同寻路的结果：起止点正确，每一步是等待或合法的移动，
+// 成本按去掉到达后等待的路径计算，单位之间没有冲突
+func checkCooperative(t *testing.T, r *AStar, agents []Agent, paths []*Path) {
+	t.Helper()
+	r.Grid().RLock()
+	defer r.Grid().RUnlock()
+	for i, path := range paths {
+		points := path.Points
+		if points[0] != agents[i].Start || points[len(points)-1] != agents[i].End {
+			t.Fatalf("agent %d: path %v does not run from %v to %v", i, points, agents[i].Start, agents[i].End)
+		}
+		for k := 1; k < len(points); k++ {
+			dx, dy := points[k].X-points[k-1].X, points[k].Y-points[k-1].Y
+			if (dx != 0 || dy != 0) && (abs(dx) > 1 || abs(dy) > 1 || !r.Grid().CanMove(r.Movement, points[k-1].X, points[k-1].Y, dx, dy)) {
+				t.Fatalf("agent %d: illegal step %v -> %v", i, points[k-1], points[k])
+			}
+		}
+		if cost := r.spaceTimeCost(trimWaits(points, agents[i].End)); cost != path.Cost {
+			t.Fatalf("agent %d: path %v costs %d, reported %d", i, points, cost, path.Cost)
+		}
+	}
+	if c, ok := firstConflict(paths); ok {
+		t.Fatalf("agents %d and %d conflict at step %d: %+v", c.a, c.b, c.t, c)
+	}
+	// 斜向交叉：两个单位同时斜向移动，两段移动的中点相同
+	length := 0
+	for _, path := range paths {
+		length = max(length, len(path.Points))
+	}
+	for k := 1; k < length; k++ {
+		for a := range paths {
+			for b := a + 1; b < len(paths); b++ {
+				pa, na := positionAt(paths[a], k-1), positionAt(paths[a], k)
+				pb, nb := positionAt(paths[b], k-1), positionAt(paths[b], k)
+				diagonal := pa.X != na.X && pa.Y != na.Y && pb.X != nb.X && pb.Y != nb.Y
+				if diagonal && pa.X+na.X == pb.X+nb.X && pa.Y+na.Y == pb.Y+nb.Y {
+					t.Fatalf("agents %d and %d cross diagonally at step %d", a, b, k-1)
+				}
+			}
+		}
+	}
+}
+
+var cooperativeModes = map[string]CooperativeOptions{
+	"hca":  {},
+	"whca": {Window: 4},
+	"cbs":  {CBS: true},
+}
+
+// 两个单位沿同一个2x2方块的两条对角线斜向交叉
+func TestCooperativeCrossing(t *testing.T) {
+	r, err := NewAStar(randomMap(rand.New(rand.NewSource(0)), 2, 2, 0, false), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	agents := []Agent{
+		{Start: Point{X: 0, Y: 0}, End: Point{X: 1, Y: 1}},
+		{Start: Point{X: 1, Y: 0}, End: Point{X: 0, Y: 1}},
+	}
+	for name, opts := range cooperativeModes {
+		paths, err := r.FindPathsCooperative(agents, opts)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		checkCooperative(t, r, agents, paths)
+	}
+}
+
+// 先规划的单位不能在第一步进入还没有规划的单位所在的格子
+func TestCooperativeHoldsStarts(t *testing.T) {
+	r, err := NewAStar(randomMap(rand.New(rand.NewSource(0)), 2, 3, 0, false), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	agents := []Agent{
+		{Start: Point{X: 0, Y: 0}, End: Point{X: 2, Y: 0}},
+		{Start: Point{X: 1, Y: 0}, End: Point{X: 1, Y: 0}},
+	}
+	for _, name := range []string{"hca", "whca"} {
+		paths, err := r.FindPathsCooperative(agents, cooperativeModes[name])
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		checkCooperative(t, r, agents, paths)
+		if paths[0].Points[1] == agents[1].Start {
+			t.Fatalf("%s: agent 0 moves onto agent 1 at step 1: %v", name, paths[0].Points)
+		}
+	}
+}
+
+// 单位先到达终点，等另一个单位经过时必须离开再回来，在终点的等待计入成本
+func TestCBSChargesGoalWaits(t *testing.T) {
+	mapData := [][]int{
+		{0, 0, 0, 0, 0, 0, 0},
+		{1, 1, 1, 1, 0, 1, 1},
+	}
+	r, err := NewAStar(mapData, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Movement = MOVEMENT_FOUR
+	agents := []Agent{
+		{Start: Point{X: 3, Y: 0}, End: Point{X: 4, Y: 0}},
+		{Start: Point{X: 0, Y: 0}, End: Point{X: 6, Y: 0}},
+	}
+	paths, err := r.FindPathsCooperative(agents, CooperativeOptions{CBS: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkCooperative(t, r, agents, paths)
+	// 单位0要离开终点让路：进出旁边的格子两步，再加上等单位1经过的两步
+	if paths[0].Cost != 5*COST_STRAIGHT || paths[1].Cost != 6*COST_STRAIGHT {
+		t.Fatalf("costs %d, %d: %v, %v", paths[0].Cost, paths[1].Cost, paths[0].Points, paths[1].Points)
+	}
+}
+
+// 随机地图上的多个单位，三种方式的结果都没有冲突
+func TestCooperativeRandom(t *testing.T) {
+	rnd := rand.New(rand.NewSource(12))
+	for i := 0; i < 30; i++ {
+		r, err := NewAStar(randomMap(rnd, 8, 8, 0.15, true), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r.Movement = []int{MOVEMENT_FOUR, MOVEMENT_EIGHT, MOVEMENT_EIGHT_NO_CORNER_CUT}[i%3]
+		used := map[Point]bool{}
+		var agents []Agent
+		for len(agents) < 4 {
+			start, end := randomWalkable(rnd, r), randomWalkable(rnd, r)
+			if !used[start] && !used[end] && start != end {
+				used[start], used[end] = true, true
+				agents = append(agents, Agent{Start: start, End: end})
+			}
+		}
+		for _, opts := range cooperativeModes {
+			paths, err := r.FindPathsCooperative(agents, opts)
+			if err != nil {
+				// 单位被困住或CBS超出预算时找不到解，只检查找到的解
+				continue
+			}
+			checkCooperative(t, r, agents, paths)
+		}
+	}
+}
@@ -0,0 +1,348 @@
+package astar
+
+import (
+	"container/heap"
+	"fmt"
+	"sync"
+)
+
+/*
+D* Lite 增量重规划
+从终点向起点反向搜索，g为节点到终点的成本，rhs为按相邻节点一步预估的成本，
+g与rhs不相等的节点（不一致节点）放进优先队列
+地图变化时只更新受影响节点的rhs，重新计算时只扩展不一致的节点，修复原有路径
+单位移动后起点改变，启发值整体偏差累加到km，队列中已有的键不需要重排
+规划器订阅共享地图的变化，变化的格子先记下来，下次Path或Move时同步到副本再更新rhs
+*/
+
+// 不可达的成本
+const dstarInf = int(^uint(0) >> 2)
+
+// 队列中节点的键，按k1、k2依次比较
+type dstarKey struct {
+	k1 int
+	k2 int
+}
+
+func (k dstarKey) less(o dstarKey) bool {
+	if k.k1 != o.k1 {
+		return k.k1 < o.k1
+	}
+	return k.k2 < o.k2
+}
+
+// D* Lite规划器
+// 在地图的副本上规划，跟随共享地图的变化，同一时间只能被一个goroutine使用
+// 移动方式和启发算法在创建时确定，之后修改AStar不影响该规划器
+type DStarLite struct {
+	astar *AStar
+	grid  *Grid
+	// 创建时的移动方式、相邻方向和启发算法
+	movement  int
+	neighbors [][]int
+	estimate  func(node, end *Node) int
+	// 共享地图上变化了、还没有同步到副本的格子
+	mu      sync.Mutex
+	pending []Point
+	cancel  func()
+	// 按x*Rows+y存放
+	g   []int
+	rhs []int
+	// 节点在队列中的键和下标，不在队列时下标为-1
+	key   []dstarKey
+	index []int
+	queue dstarQueue
+	km    int
+	// 起点（单位当前位置）、上次计算启发值时的起点、终点
+	start Point
+	last  Point
+	end   Point
+	// 最近一次计算扩展的节点数
+	expanded int
+}
+
+// 生成从start到end的规划器，并订阅地图变化，不再使用时调用Close
+func (r *AStar) NewDStarLite(start, end *Node) (*DStarLite, error) {
+	d := &DStarLite{
+		astar:     r,
+		movement:  r.Movement,
+		neighbors: r.neighborPos(),
+		estimate:  r.Heuristic,
+		start:     Point{X: start.X, Y: start.Y},
+		last:      Point{X: start.X, Y: start.Y},
+		end:       Point{X: end.X, Y: end.Y},
+	}
+	if d.estimate == nil {
+		d.estimate = Diagonal
+		if d.movement == MOVEMENT_FOUR {
+			d.estimate = Manhattan
+		}
+	}
+	// 先订阅再复制，复制前后的变化都会在下次同步时按共享地图的当前值写入副本
+	d.cancel = r.grid.Subscribe(d.onChange)
+	d.grid = r.grid.Clone()
+	if err := checkEndpoints(d.grid, start, end); err != nil {
+		d.cancel()
+		return nil, err
+	}
+	d.init()
+	return d, nil
+}
+
+// 停止跟随地图变化
+func (d *DStarLite) Close() {
+	d.cancel()
+}
+
+func (d *DStarLite) init() {
+	size := d.grid.Rows * d.grid.Cols
+	d.g = make([]int, size)
+	d.rhs = make([]int, size)
+	d.key = make([]dstarKey, size)
+	d.index = make([]int, size)
+	for i := 0; i < size; i++ {
+		d.g[i], d.rhs[i], d.index[i] = dstarInf, dstarInf, -1
+	}
+	d.queue = dstarQueue{d: d}
+	d.km = 0
+	d.last = d.start
+	end := d.id(d.end.X, d.end.Y)
+	d.rhs[end] = 0
+	d.push(end)
+}
+
+// 当前位置到终点的路径，只扩展地图变化后不一致的节点
+func (d *DStarLite) Path() (*Path, error) {
+	d.sync()
+	d.computeShortestPath()
+	start := d.id(d.start.X, d.start.Y)
+	if d.g[start] >= dstarInf {
+		return nil, ErrNoPath
+	}
+	path := &Path{
+		Points:   []Point{d.start},
+		Cost:     d.g[start],
+		Expanded: d.expanded,
+	}
+	// 每一步走向成本最小的相邻节点
+	x, y := d.start.X, d.start.Y
+	for steps := 0; x != d.end.X || y != d.end.Y; steps++ {
+		if steps > len(d.g) {
+			return nil, ErrNoPath
+		}
+		bx, by, best := -1, -1, dstarInf
+		d.eachNeighbor(x, y, func(nx, ny int) {
+			if cost := d.grid.StepCost(x, y, nx, ny) + d.g[d.id(nx, ny)]; cost < best {
+				bx, by, best = nx, ny, cost
+			}
+		})
+		if bx < 0 {
+			return nil, ErrNoPath
+		}
+		x, y = bx, by
+		path.Points = append(path.Points, Point{X: x, Y: y})
+	}
+	return path, nil
+}
+
+// 单位移动到x,y，越界返回ErrOutOfBounds，x,y不可行返回ErrStartBlocked
+func (d *DStarLite) Move(x, y int) error {
+	d.sync()
+	if !d.grid.InBounds(x, y) {
+		return fmt.Errorf("%d,%d: %w", x, y, ErrOutOfBounds)
+	}
+	if !d.grid.IsWalkable(x, y) {
+		return fmt.Errorf("%d,%d: %w", x, y, ErrStartBlocked)
+	}
+	d.start = Point{X: x, Y: y}
+	// 起点变化产生的启发值偏差
+	d.km += d.heuristic(d.last.X, d.last.Y)
+	d.last = d.start
+	return nil
+}
+
+// 修改共享地图中的节点类型，成本倍率取该类型的默认值
+// 与Grid().SetType相同，其他寻路同样会看到这次修改
+func (d *DStarLite) SetType(x, y, t int) error {
+	return d.astar.grid.SetType(x, y, t)
+}
+
+// 修改共享地图中的节点类型和成本倍率
+func (d *DStarLite) SetCell(x, y, t, rate int) error {
+	return d.astar.grid.Set(x, y, t, rate)
+}
+
+// 记下变化的格子，不读取地图
+func (d *DStarLite) onChange(change Change) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.pending = append(d.pending, Point{X: change.X, Y: change.Y})
+}
+
+// 把变化的格子按共享地图的当前值写入副本
+// 回调的顺序可能与修改的顺序不同，只记坐标、同步时读取当前值，副本与共享地图一致
+func (d *DStarLite) sync() {
+	d.mu.Lock()
+	cells := d.pending
+	d.pending = nil
+	d.mu.Unlock()
+	if len(cells) == 0 {
+		return
+	}
+	shared := d.astar.grid
+	values := make([][2]int, len(cells))
+	shared.RLock()
+	for i, p := range cells {
+		values[i] = [2]int{shared.TypeAt(p.X, p.Y), shared.RateAt(p.X, p.Y)}
+	}
+	shared.RUnlock()
+	for i, p := range cells {
+		d.apply(p.X, p.Y, values[i][0], values[i][1])
+	}
+}
+
+// 修改副本中的格子，更新受影响节点的rhs
+func (d *DStarLite) apply(x, y, t, rate int) {
+	minRate := d.grid.MinRate()
+	if d.grid.TypeAt(x, y) == t && d.grid.RateAt(x, y) == rate {
+		return
+	}
+	// 值来自共享地图，已经校验过
+	d.grid.Set(x, y, t, rate)
+	// 最小倍率变小后原有的启发值可能高估，队列的键全部失效，重新规划
+	if d.grid.MinRate() < minRate {
+		d.init()
+		return
+	}
+	// x,y周围一圈的节点都可能经过x,y或从x,y的拐角斜向移动
+	for dx := -1; dx <= 1; dx++ {
+		for dy := -1; dy <= 1; dy++ {
+			if d.grid.InBounds(x+dx, y+dy) {
+				d.updateVertex(x+dx, y+dy)
+			}
+		}
+	}
+}
+
+func (d *DStarLite) computeShortestPath() {
+	d.expanded = 0
+	start := d.id(d.start.X, d.start.Y)
+	for d.queue.Len() > 0 {
+		top := d.queue.items[0]
+		if !d.key[top].less(d.calculateKey(start)) && d.rhs[start] == d.g[start] {
+			break
+		}
+		old := d.key[top]
+		x, y := d.point(top)
+		if k := d.calculateKey(top); old.less(k) {
+			// 键已过期，按新键重新排序
+			d.key[top] = k
+			heap.Fix(&d.queue, d.index[top])
+			continue
+		}
+		d.expanded++
+		heap.Pop(&d.queue)
+		if d.g[top] > d.rhs[top] {
+			d.g[top] = d.rhs[top]
+		} else {
+			d.g[top] = dstarInf
+			d.updateVertex(x, y)
+		}
+		d.eachNeighbor(x, y, func(nx, ny int) {
+			d.updateVertex(nx, ny)
+		})
+	}
+}
+
+// 重新计算节点的rhs，不一致时放进队列
+func (d *DStarLite) updateVertex(x, y int) {
+	id := d.id(x, y)
+	if x != d.end.X || y != d.end.Y {
+		rhs := dstarInf
+		if d.grid.IsWalkable(x, y) {
+			d.eachNeighbor(x, y, func(nx, ny int) {
+				if g := d.g[d.id(nx, ny)]; g < dstarInf {
+					rhs = min(rhs, d.grid.StepCost(x, y, nx, ny)+g)
+				}
+			})
+		}
+		d.rhs[id] = rhs
+	}
+	if d.index[id] >= 0 {
+		heap.Remove(&d.queue, d.index[id])
+	}
+	if d.g[id] != d.rhs[id] {
+		d.push(id)
+	}
+}
+
+func (d *DStarLite) push(id int) {
+	d.key[id] = d.calculateKey(id)
+	heap.Push(&d.queue, id)
+}
+
+func (d *DStarLite) calculateKey(id int) dstarKey {
+	x, y := d.point(id)
+	m := min(d.g[id], d.rhs[id])
+	if m >= dstarInf {
+		return dstarKey{k1: dstarInf, k2: dstarInf}
+	}
+	return dstarKey{k1: m + d.heuristic(x, y) + d.km, k2: m}
+}
+
+// 节点到当前起点的启发值
+func (d *DStarLite) heuristic(x, y int) int {
+	h := d.estimate(&Node{X: x, Y: y}, &Node{X: d.start.X, Y: d.start.Y})
+	return d.grid.ScaleHeuristic(h)
+}
+
+// 按移动方式遍历可以到达的相邻节点，移动规则双向对称，前驱与后继相同
+func (d *DStarLite) eachNeighbor(x, y int, f func(nx, ny int)) {
+	for _, v := range d.neighbors {
+		if d.grid.CanMove(d.movement, x, y, v[0], v[1]) {
+			f(x+v[0], y+v[1])
+		}
+	}
+}
+
+func (d *DStarLite) id(x, y int) int {
+	return x*d.grid.Rows + y
+}
+
+func (d *DStarLite) point(id int) (int, int) {
+	return id / d.grid.Rows, id % d.grid.Rows
+}
+
+// D* Lite优先队列，存放节点下标
+type dstarQueue struct {
+	d     *DStarLite
+	items []int
+}
+
+func (q dstarQueue) Len() int {
+	return len(q.items)
+}
+
+func (q dstarQueue) Less(i, j int) bool {
+	return q.d.key[q.items[i]].less(q.d.key[q.items[j]])
+}
+
+func (q dstarQueue) Swap(i, j int) {
+	q.items[i], q.items[j] = q.items[j], q.items[i]
+	q.d.index[q.items[i]] = i
+	q.d.index[q.items[j]] = j
+}
+
+func (q *dstarQueue) Push(x any) {
+	id := x.(int)
+	q.d.index[id] = len(q.items)
+	q.items = append(q.items, id)
+}
+
+func (q *dstarQueue) Pop() any {
+	n := len(q.items) - 1
+	id := q.items[n]
+	q.items = q.items[:n]
+	q.d.index[id] = -1
+	return id
+}
@@ -0,0 +1,119 @@
+package astar
+
+import (
+	"errors"
+	"math/rand"
+	"testing"
+)
+
+// 随机修改地图、沿路径移动后，D* Lite的路径成本与在修改后的地图上重新搜索的最短路径一致
+func TestDStarLiteReplans(t *testing.T) {
+	rnd := rand.New(rand.NewSource(4))
+	mapData := randomMap(rnd, 30, 30, 0.2, true)
+	start, end := Point{X: 0, Y: 0}, Point{X: 29, Y: 29}
+	mapData[start.Y][start.X], mapData[end.Y][end.X] = NODE_TYPE_NORMAL, NODE_TYPE_NORMAL
+	r, err := NewAStar(mapData, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Movement = MOVEMENT_EIGHT_NO_CORNER_CUT
+	d, err := r.NewDStarLite(&Node{X: start.X, Y: start.Y}, &Node{X: end.X, Y: end.Y})
+	if err != nil {
+		t.Fatal(err)
+	}
+	types := []int{NODE_TYPE_NORMAL, NODE_TYPE_OBSTACLE, NODE_TYPE_ROAD, NODE_TYPE_SWAMP, NODE_TYPE_WATER}
+	for i := 0; i < 200; i++ {
+		x, y := rnd.Intn(30), rnd.Intn(30)
+		if (x != start.X || y != start.Y) && (x != end.X || y != end.Y) {
+			mapData[y][x] = types[rnd.Intn(len(types))]
+			if err := d.SetType(x, y, mapData[y][x]); err != nil {
+				t.Fatal(err)
+			}
+		}
+		got, err := d.Path()
+		fresh, freshErr := NewAStar(mapData, nil)
+		if freshErr != nil {
+			t.Fatal(freshErr)
+		}
+		fresh.Movement = r.Movement
+		want, wantErr := fresh.FindPath(&Node{X: start.X, Y: start.Y}, &Node{X: end.X, Y: end.Y})
+		if errors.Is(wantErr, ErrNoPath) {
+			if !errors.Is(err, ErrNoPath) {
+				t.Fatalf("edit %d: want ErrNoPath, got %v", i, err)
+			}
+			continue
+		}
+		if wantErr != nil || err != nil {
+			t.Fatalf("edit %d: %v, %v", i, wantErr, err)
+		}
+		if got.Cost != want.Cost {
+			t.Fatalf("edit %d: cost %d, shortest %d", i, got.Cost, want.Cost)
+		}
+		checkPath(t, fresh, got, start, end)
+		// 每隔几次修改沿路径走一步
+		if i%3 == 0 && len(got.Points) > 1 {
+			start = got.Points[1]
+			if err := d.Move(start.X, start.Y); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if err := d.Move(-1, 0); !errors.Is(err, ErrOutOfBounds) {
+		t.Fatalf("move out of bounds: %v", err)
+	}
+	if err := d.Move(30, 29); !errors.Is(err, ErrOutOfBounds) {
+		t.Fatalf("move out of bounds: %v", err)
+	}
+	r.Grid().SetWalkable(1, 0, false)
+	if err := d.Move(1, 0); !errors.Is(err, ErrStartBlocked) {
+		t.Fatalf("move onto an obstacle: %v", err)
+	}
+}
+
+// 修改共享地图后D* Lite的路径成本与重新搜索的最短路径一致
+func TestDStarLiteFollowsGrid(t *testing.T) {
+	rnd := rand.New(rand.NewSource(4))
+	r, err := NewAStar(randomMap(rnd, 30, 30, 0.2, true), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Movement = MOVEMENT_EIGHT_NO_CORNER_CUT
+	start, end := Point{X: 0, Y: 0}, Point{X: 29, Y: 29}
+	r.Grid().SetWalkable(start.X, start.Y, true)
+	r.Grid().SetWalkable(end.X, end.Y, true)
+	d, err := r.NewDStarLite(&Node{X: start.X, Y: start.Y}, &Node{X: end.X, Y: end.Y})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	// 创建后修改AStar不影响规划器
+	r.Movement = MOVEMENT_FOUR
+	for i := 0; i < 100; i++ {
+		x, y := rnd.Intn(30), rnd.Intn(30)
+		if (x != start.X || y != start.Y) && (x != end.X || y != end.Y) {
+			if rnd.Intn(2) == 0 {
+				r.Grid().SetWalkable(x, y, rnd.Intn(3) != 0)
+			} else if err := d.SetType(x, y, NODE_TYPE_SWAMP); err != nil {
+				t.Fatal(err)
+			}
+		}
+		got, err := d.Path()
+		r.Movement = MOVEMENT_EIGHT_NO_CORNER_CUT
+		want, wantErr := r.FindPath(&Node{X: start.X, Y: start.Y}, &Node{X: end.X, Y: end.Y})
+		if errors.Is(wantErr, ErrNoPath) {
+			if !errors.Is(err, ErrNoPath) {
+				t.Fatalf("edit %d: want ErrNoPath, got %v", i, err)
+			}
+			r.Movement = MOVEMENT_FOUR
+			continue
+		}
+		if wantErr != nil || err != nil {
+			t.Fatalf("edit %d: %v, %v", i, wantErr, err)
+		}
+		if got.Cost != want.Cost {
+			t.Fatalf("edit %d: cost %d, shortest %d", i, got.Cost, want.Cost)
+		}
+		checkPath(t, r, got, start, end)
+		r.Movement = MOVEMENT_FOUR
+	}
+}
@@ -0,0 +1,242 @@
+package astar
+
+import (
+	"container/heap"
+	"fmt"
+	"math"
+	"sync"
+)
+
+/*
+流场
+从终点反向执行Dijkstra，得到每个格子到终点的成本（积分场），
+每个格子的方向指向成本最低的下一步，所有单位共用一个流场，每帧按所在格子查询方向即可
+格子变化时只重算受影响的部分：
+  以变化格子及其周围移动不再合法的格子为根，沿方向反查出依赖它们的格子，重置为不可达，
+  再从这些格子周围仍然有效的格子和变化格子周围的格子重新向外松弛
+*/
+
+// 流场，可被多个goroutine同时查询
+type FlowField struct {
+	astar *AStar
+	goal  Point
+	mu    sync.RWMutex
+	// 到终点的成本，按x*Rows+y存放，不可达为math.MaxInt
+	costs []int
+	// 下一步的移动方向，终点和不可达的格子为0,0
+	dirs []Point
+	// 取消订阅地图变化
+	cancel func()
+}
+
+// 生成到goal的流场，并订阅地图变化
+func (r *AStar) NewFlowField(goal *Node) (*FlowField, error) {
+	if !r.grid.InBounds(goal.X, goal.Y) {
+		return nil, fmt.Errorf("goal %d,%d: %w", goal.X, goal.Y, ErrOutOfBounds)
+	}
+	f := &FlowField{
+		astar: r,
+		goal:  Point{X: goal.X, Y: goal.Y},
+		costs: make([]int, r.grid.Rows*r.grid.Cols),
+		dirs:  make([]Point, r.grid.Rows*r.grid.Cols),
+	}
+	// 先订阅再构建，构建期间的变化在构建完成后由onChange处理，不会丢失
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.cancel = r.grid.Subscribe(f.onChange)
+	r.grid.RLock()
+	defer r.grid.RUnlock()
+	f.build()
+	return f, nil
+}
+
+// 停止跟随地图变化
+func (f *FlowField) Close() {
+	f.cancel()
+}
+
+// 流场的终点
+func (f *FlowField) Goal() Point {
+	return f.goal
+}
+
+// 格子到终点的成本，不可达或越界时返回false
+func (f *FlowField) Cost(x, y int) (int, bool) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	if !f.astar.grid.InBounds(x, y) {
+		return 0, false
+	}
+	cost := f.costs[f.id(x, y)]
+	return cost, cost != math.MaxInt
+}
+
+// 格子下一步的移动方向，位于终点、不可达或越界时返回false
+func (f *FlowField) Direction(x, y int) (int, int, bool) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	if !f.astar.grid.InBounds(x, y) {
+		return 0, 0, false
+	}
+	id := f.id(x, y)
+	if f.costs[id] == math.MaxInt || f.costs[id] == 0 {
+		return 0, 0, false
+	}
+	return f.dirs[id].X, f.dirs[id].Y, true
+}
+
+// 从终点重新计算整个流场，调用方持有地图的读锁
+func (f *FlowField) build() {
+	for i := range f.costs {
+		f.costs[i] = math.MaxInt
+		f.dirs[i] = Point{}
+	}
+	queue := &flowQueue{}
+	if f.astar.grid.IsWalkable(f.goal.X, f.goal.Y) {
+		id := f.id(f.goal.X, f.goal.Y)
+		f.costs[id] = 0
+		heap.Push(queue, flowItem{id: id})
+	}
+	f.relax(queue)
+}
+
+// 从队列中的格子向外松弛，直到队列为空
+func (f *FlowField) relax(queue *flowQueue) {
+	g := f.astar.grid
+	for queue.Len() > 0 {
+		item := heap.Pop(queue).(flowItem)
+		if item.cost != f.costs[item.id] {
+			continue
+		}
+		x, y := f.point(item.id)
+		// 反向松弛：从相邻格子能移动到当前格子时，更新相邻格子的成本
+		for _, v := range f.astar.neighborPos() {
+			nx, ny := x-v[0], y-v[1]
+			if !g.IsWalkable(nx, ny) || !g.CanMove(f.astar.Movement, nx, ny, v[0], v[1]) {
+				continue
+			}
+			cost := item.cost + g.StepCost(nx, ny, x, y)
+			id := f.id(nx, ny)
+			if cost < f.costs[id] {
+				f.costs[id] = cost
+				f.dirs[id] = Point{X: v[0], Y: v[1]}
+				heap.Push(queue, flowItem{id: id, cost: cost})
+			}
+		}
+	}
+}
+
+// 格子变化时重算受影响的部分
+func (f *FlowField) onChange(change Change) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	g := f.astar.grid
+	g.RLock()
+	defer g.RUnlock()
+	if change.X == f.goal.X && change.Y == f.goal.Y {
+		f.build()
+		return
+	}
+	// 依赖变化格子的格子，以及周围下一步不再合法的格子
+	roots := []int{f.id(change.X, change.Y)}
+	for _, v := range allPos {
+		x, y := change.X+v[0], change.Y+v[1]
+		if !g.InBounds(x, y) {
+			continue
+		}
+		id := f.id(x, y)
+		dir := f.dirs[id]
+		if f.costs[id] != math.MaxInt && f.costs[id] != 0 && !g.CanMove(f.astar.Movement, x, y, dir.X, dir.Y) {
+			roots = append(roots, id)
+		}
+	}
+	affected := f.dependents(roots)
+	for _, id := range affected {
+		f.costs[id] = math.MaxInt
+		f.dirs[id] = Point{}
+	}
+	// 受影响格子周围和变化格子周围仍然有效的格子重新松弛
+	queue := &flowQueue{}
+	seeds := append(affected, roots[0])
+	for _, id := range seeds {
+		x, y := f.point(id)
+		for _, v := range allPos {
+			nx, ny := x+v[0], y+v[1]
+			if !g.InBounds(nx, ny) {
+				continue
+			}
+			nid := f.id(nx, ny)
+			if f.costs[nid] != math.MaxInt {
+				heap.Push(queue, flowItem{id: nid, cost: f.costs[nid]})
+			}
+		}
+	}
+	f.relax(queue)
+}
+
+// 沿方向反查，返回下一步依次经过roots中任意格子的所有格子，包括roots
+func (f *FlowField) dependents(roots []int) []int {
+	seen := make(map[int]bool, len(roots))
+	result := make([]int, 0, len(roots))
+	for _, id := range roots {
+		if !seen[id] {
+			seen[id] = true
+			result = append(result, id)
+		}
+	}
+	for i := 0; i < len(result); i++ {
+		x, y := f.point(result[i])
+		for _, v := range allPos {
+			nx, ny := x-v[0], y-v[1]
+			if !f.astar.grid.InBounds(nx, ny) {
+				continue
+			}
+			id := f.id(nx, ny)
+			if !seen[id] && f.costs[id] != math.MaxInt && f.dirs[id] == (Point{X: v[0], Y: v[1]}) {
+				seen[id] = true
+				result = append(result, id)
+			}
+		}
+	}
+	return result
+}
+
+func (f *FlowField) id(x, y int) int {
+	return x*f.astar.grid.Rows + y
+}
+
+func (f *FlowField) point(id int) (int, int) {
+	return id / f.astar.grid.Rows, id % f.astar.grid.Rows
+}
+
+// 流场计算的优先队列，成本过期的元素在取出时跳过
+type flowItem struct {
+	id   int
+	cost int
+}
+
+type flowQueue []flowItem
+
+func (q flowQueue) Len() int {
+	return len(q)
+}
+
+func (q flowQueue) Less(i, j int) bool {
+	return q[i].cost < q[j].cost
+}
+
+func (q flowQueue) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+}
+
+func (q *flowQueue) Push(x any) {
+	*q = append(*q, x.(flowItem))
+}
+
+func (q *flowQueue) Pop() any {
+	s := *q
+	n := len(s) - 1
+	item := s[n]
+	*q = s[:n]
+	return item
+}
@@ -0,0 +1,109 @@
+package astar
+
+import (
+	"math/rand"
+	"sync"
+	"testing"
+)
+
+// 流场的成本等于A*到终点的成本，沿方向前进成本按每一步递减
+// 依次修改地图后，增量修复的流场与重新生成的流场一致
+func TestFlowField(t *testing.T) {
+	rnd := rand.New(rand.NewSource(19))
+	r, err := NewAStar(randomMap(rnd, 30, 30, 0.2, true), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	goal := randomWalkable(rnd, r)
+	f, err := r.NewFlowField(&Node{X: goal.X, Y: goal.Y})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	for i := 0; i < 100; i++ {
+		p := randomWalkable(rnd, r)
+		cost, ok := f.Cost(p.X, p.Y)
+		path, err := r.FindPath(&Node{X: p.X, Y: p.Y}, &Node{X: goal.X, Y: goal.Y})
+		if ok != (err == nil) || ok && cost != path.Cost {
+			t.Fatalf("%v: field cost %d,%v, astar %v %v", p, cost, ok, path, err)
+		}
+		if dx, dy, ok := f.Direction(p.X, p.Y); ok {
+			next, _ := f.Cost(p.X+dx, p.Y+dy)
+			if step := r.Grid().StepCost(p.X, p.Y, p.X+dx, p.Y+dy); next != cost-step {
+				t.Fatalf("%v: step %d,%d costs %d, field %d -> %d", p, dx, dy, step, cost, next)
+			}
+		}
+	}
+	terrains := []int{NODE_TYPE_NORMAL, NODE_TYPE_ROAD, NODE_TYPE_SWAMP, NODE_TYPE_WATER}
+	for i := 0; i < 200; i++ {
+		x, y := rnd.Intn(30), rnd.Intn(30)
+		if x == goal.X && y == goal.Y {
+			continue
+		}
+		if i%2 == 0 {
+			err = r.Grid().SetWalkable(x, y, rnd.Intn(3) != 0)
+		} else {
+			err = r.Grid().SetType(x, y, terrains[rnd.Intn(len(terrains))])
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if i%10 != 9 {
+			continue
+		}
+		fresh, err := r.NewFlowField(&Node{X: goal.X, Y: goal.Y})
+		if err != nil {
+			t.Fatal(err)
+		}
+		fresh.Close()
+		for y := 0; y < 30; y++ {
+			for x := 0; x < 30; x++ {
+				a, okA := f.Cost(x, y)
+				b, okB := fresh.Cost(x, y)
+				if a != b || okA != okB {
+					t.Fatalf("edit %d cell %d,%d: cost %d,%v, rebuilt %d,%v", i, x, y, a, okA, b, okB)
+				}
+			}
+		}
+	}
+}
+
+// 创建流场的同时修改地图，修改完成后流场与重新生成的流场一致
+func TestFlowFieldFollowsEditsDuringBuild(t *testing.T) {
+	rnd := rand.New(rand.NewSource(3))
+	r, err := NewAStar(randomMap(rnd, 40, 40, 0.2, true), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 20; i++ {
+		var wg sync.WaitGroup
+		wg.Add(1)
+		go func(seed int64) {
+			defer wg.Done()
+			rnd := rand.New(rand.NewSource(seed))
+			for j := 0; j < 50; j++ {
+				r.Grid().SetWalkable(rnd.Intn(40), rnd.Intn(40), rnd.Intn(3) != 0)
+			}
+		}(int64(i))
+		f, err := r.NewFlowField(&Node{X: 20, Y: 20})
+		if err != nil {
+			t.Fatal(err)
+		}
+		wg.Wait()
+		fresh, err := r.NewFlowField(&Node{X: 20, Y: 20})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for y := 0; y < 40; y++ {
+			for x := 0; x < 40; x++ {
+				a, okA := f.Cost(x, y)
+				b, okB := fresh.Cost(x, y)
+				if a != b || okA != okB {
+					t.Fatalf("round %d cell %d,%d: cost %d,%v, rebuilt %d,%v", i, x, y, a, okA, b, okB)
+				}
+			}
+		}
+		f.Close()
+		fresh.Close()
+	}
+}
@@ -0,0 +1,147 @@
+package astar
+
+import "context"
+
+/*
+通用图
+A*只依赖三件事：相邻节点、移动成本和启发值，实现Graph接口即可在任意图上搜索，
+节点编号是任意可比较的类型，例如方格坐标、六边形坐标、路点或导航网格多边形的编号
+通用图与方格寻路使用同一个搜索循环（Searcher.run），图的节点在第一次访问时分配搜索节点
+方格地图本身也是一种实现（GridGraph），搜索期间持有地图的读锁，起止点按原样使用，
+不受SnapRadius、Redirect影响
+*/
+
+// 图，K为节点编号
+type Graph[K comparable] interface {
+	// 从node能直接到达的相邻节点
+	Neighbors(node K) []K
+	// 从node移动到相邻节点next的成本
+	Cost(node, next K) int
+	// 从node到end的估计成本，不能高于实际成本
+	Heuristic(node, end K) int
+}
+
+// 图上的路径
+type GraphPath[K comparable] struct {
+	// 从起点到终点依次经过的节点
+	Nodes []K
+	// 总移动成本
+	Cost int
+	// 搜索过程中扩展（关闭）的节点数量
+	Expanded int
+}
+
+// 在图上寻路，找不到路径时返回ErrNoPath
+// 图实现了RLock、RUnlock时（如GridGraph），在整个搜索期间持有读锁
+func FindPathGraph[K comparable](graph Graph[K], start, end K) (*GraphPath[K], error) {
+	if l, ok := graph.(rLocker); ok {
+		l.RLock()
+		defer l.RUnlock()
+	}
+	view := &graphView[K]{
+		graph: graph,
+		end:   end,
+		nodes: make(map[K]*Node),
+		ids:   make(map[*Node]K),
+	}
+	// 通用图没有预算和膨胀系数
+	s := &Searcher{exact: true}
+	node, _ := s.run(context.Background(), view, view.node(start), view.node(end))
+	if node == nil {
+		return nil, ErrNoPath
+	}
+	path := &GraphPath[K]{Cost: node.G, Expanded: len(s.closeList)}
+	for ; node != nil; node = node.Parent {
+		path.Nodes = append(path.Nodes, view.ids[node])
+	}
+	for i, j := 0, len(path.Nodes)-1; i < j; i, j = i+1, j-1 {
+		path.Nodes[i], path.Nodes[j] = path.Nodes[j], path.Nodes[i]
+	}
+	return path, nil
+}
+
+// 搜索期间需要持有读锁的图
+type rLocker interface {
+	RLock()
+	RUnlock()
+}
+
+// 通用图在搜索循环中的视图，图的节点第一次访问时分配搜索节点
+type graphView[K comparable] struct {
+	graph Graph[K]
+	end   K
+	nodes map[K]*Node
+	// 搜索节点对应的图节点
+	ids map[*Node]K
+}
+
+func (v *graphView[K]) node(id K) *Node {
+	node, ok := v.nodes[id]
+	if !ok {
+		node = &Node{index: -1}
+		v.nodes[id] = node
+		v.ids[node] = id
+	}
+	return node
+}
+
+func (v *graphView[K]) neighbors(node *Node) []*Node {
+	ids := v.graph.Neighbors(v.ids[node])
+	neighbors := make([]*Node, len(ids))
+	for i, id := range ids {
+		neighbors[i] = v.node(id)
+	}
+	return neighbors
+}
+
+func (v *graphView[K]) cost(node, next *Node) int {
+	return v.graph.Cost(v.ids[node], v.ids[next])
+}
+
+func (v *graphView[K]) heuristic(node *Node) int {
+	return v.graph.Heuristic(v.ids[node], v.end)
+}
+
+// 方格地图，按AStar的移动方式、启发算法和成本倍率实现Graph
+// FindPathGraph通过RLock、RUnlock在整个搜索期间持有地图的读锁，单独调用其他方法时调用方需要先加锁
+type GridGraph struct {
+	astar *AStar
+}
+
+// 把地图作为通用图
+func (r *AStar) Graph() *GridGraph {
+	return &GridGraph{astar: r}
+}
+
+func (g *GridGraph) RLock() {
+	g.astar.grid.RLock()
+}
+
+func (g *GridGraph) RUnlock() {
+	g.astar.grid.RUnlock()
+}
+
+// 越界或不可行的格子没有相邻节点
+func (g *GridGraph) Neighbors(p Point) []Point {
+	grid := g.astar.grid
+	if !grid.IsWalkable(p.X, p.Y) {
+		return nil
+	}
+	neighbors := make([]Point, 0, 8)
+	for _, v := range g.astar.neighborPos() {
+		if grid.CanMove(g.astar.Movement, p.X, p.Y, v[0], v[1]) {
+			neighbors = append(neighbors, Point{X: p.X + v[0], Y: p.Y + v[1]})
+		}
+	}
+	return neighbors
+}
+
+func (g *GridGraph) Cost(p, next Point) int {
+	grid := g.astar.grid
+	return grid.StepCost(p.X, p.Y, next.X, next.Y)
+}
+
+func (g *GridGraph) Heuristic(p, end Point) int {
+	grid := g.astar.grid
+	return grid.ScaleHeuristic(g.astar.heuristic(&Node{X: p.X, Y: p.Y}, &Node{X: end.X, Y: end.Y}))
+}
@@ -0,0 +1,276 @@
+package astar
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync"
+	"testing"
+)
+
+// 方格图上的通用搜索与FindPath成本相同，路径首尾是起止点，被障碍围住的终点找不到路径
+func TestFindPathGraphGrid(t *testing.T) {
+	mapData := [][]int{
+		{0, 0, 0, 0, 0, 0, 1, 0},
+		{0, 1, 1, 1, 1, 0, 1, 1},
+		{0, 0, 0, 0, 1, 0, 0, 0},
+		{1, 1, 1, 0, 0, 0, 0, 0},
+	}
+	r, err := NewAStar(mapData, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	start, end := Point{X: 0, Y: 2}, Point{X: 5, Y: 3}
+	want, err := r.FindPath(&Node{X: start.X, Y: start.Y}, &Node{X: end.X, Y: end.Y})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := FindPathGraph[Point](r.Graph(), start, end)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Cost != want.Cost || got.Nodes[0] != start || got.Nodes[len(got.Nodes)-1] != end {
+		t.Errorf("graph path %v cost %d, FindPath cost %d", got.Nodes, got.Cost, want.Cost)
+	}
+	if _, err := FindPathGraph[Point](r.Graph(), start, Point{X: 7, Y: 0}); !errors.Is(err, ErrNoPath) {
+		t.Errorf("enclosed end: %v, want ErrNoPath", err)
+	}
+}
+
+// 空六边形地图上的成本是格子距离乘直线成本，被障碍围住的格子无法到达
+func TestFindPathGraphHex(t *testing.T) {
+	g := NewHexGraph(3)
+	start, end := Hex{Q: -3, R: 0}, Hex{Q: 3, R: -3}
+	path, err := FindPathGraph[Hex](g, start, end)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := hexDistance(start, end) * COST_STRAIGHT; path.Cost != want || len(path.Nodes) != hexDistance(start, end)+1 {
+		t.Errorf("path %v cost %d, want %d", path.Nodes, path.Cost, want)
+	}
+	for _, d := range hexDirections {
+		g.Obstacles[Hex{Q: end.Q + d.Q, R: end.R + d.R}] = true
+	}
+	if _, err := FindPathGraph[Hex](g, start, end); !errors.Is(err, ErrNoPath) {
+		t.Errorf("enclosed end: %v, want ErrNoPath", err)
+	}
+}
+
+// 路点图选择较短的一条路线，成本是各段距离之和
+func TestFindPathGraphWaypoints(t *testing.T) {
+	g := &WaypointGraph{}
+	a := g.AddPoint(Waypoint{X: 0, Y: 0})
+	near := g.AddPoint(Waypoint{X: 3, Y: 4})
+	far := g.AddPoint(Waypoint{X: 3, Y: -10})
+	b := g.AddPoint(Waypoint{X: 6, Y: 0})
+	g.Connect(a, near)
+	g.Connect(near, b)
+	g.Connect(a, far)
+	g.Connect(far, b)
+	path, err := FindPathGraph[int](g, a, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []int{a, near, b}; len(path.Nodes) != 3 || path.Nodes[1] != near || path.Cost != 10*COST_STRAIGHT {
+		t.Errorf("path %v cost %d, want %v cost %d", path.Nodes, path.Cost, want, 10*COST_STRAIGHT)
+	}
+}
+
+// 启发值为0的图，搜索退化为Dijkstra，得到最短路径的成本
+type zeroHeuristic[K comparable] struct {
+	Graph[K]
+}
+
+func (zeroHeuristic[K]) Heuristic(node, end K) int {
+	return 0
+}
+
+// 在图上随机寻路，成本与Dijkstra一致，启发值不高于实际成本，路径上相邻节点的成本之和等于路径成本
+func checkGraph[K comparable](t *testing.T, graph Graph[K], pairs [][2]K) {
+	t.Helper()
+	for _, pair := range pairs {
+		start, end := pair[0], pair[1]
+		want, wantErr := FindPathGraph[K](zeroHeuristic[K]{graph}, start, end)
+		got, err := FindPathGraph(graph, start, end)
+		if errors.Is(wantErr, ErrNoPath) {
+			if !errors.Is(err, ErrNoPath) {
+				t.Fatalf("%v -> %v: want ErrNoPath, got %v", start, end, err)
+			}
+			continue
+		}
+		if wantErr != nil || err != nil {
+			t.Fatalf("%v -> %v: %v, %v", start, end, wantErr, err)
+		}
+		if got.Cost != want.Cost {
+			t.Fatalf("%v -> %v: cost %d, shortest %d", start, end, got.Cost, want.Cost)
+		}
+		if h := graph.Heuristic(start, end); h > want.Cost {
+			t.Fatalf("%v -> %v: heuristic %d overestimates %d", start, end, h, want.Cost)
+		}
+		cost := 0
+		for i := 1; i < len(got.Nodes); i++ {
+			cost += graph.Cost(got.Nodes[i-1], got.Nodes[i])
+		}
+		if got.Nodes[0] != start || got.Nodes[len(got.Nodes)-1] != end || cost != got.Cost {
+			t.Fatalf("%v -> %v: path %v costs %d, reported %d", start, end, got.Nodes, cost, got.Cost)
+		}
+	}
+}
+
+func TestHexGraph(t *testing.T) {
+	rnd := rand.New(rand.NewSource(9))
+	g := NewHexGraph(8)
+	var cells []Hex
+	for q := -8; q <= 8; q++ {
+		for r := -8; r <= 8; r++ {
+			if h := (Hex{Q: q, R: r}); hexDistance(h, Hex{}) <= 8 {
+				cells = append(cells, h)
+			}
+		}
+	}
+	// 倍率33时每一步10*33/100不是整数，向下取整会让启发值高估
+	for _, h := range cells {
+		switch rnd.Intn(5) {
+		case 0:
+			g.Obstacles[h] = true
+		case 1:
+			if err := g.SetRate(h, 33); err != nil {
+				t.Fatal(err)
+			}
+		case 2:
+			if err := g.SetRate(h, 250); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if err := g.SetRate(Hex{}, 0); !errors.Is(err, ErrInvalidMap) {
+		t.Fatalf("rate 0: %v", err)
+	}
+	pairs := make([][2]Hex, 300)
+	for i := range pairs {
+		pairs[i] = [2]Hex{cells[rnd.Intn(len(cells))], cells[rnd.Intn(len(cells))]}
+	}
+	checkGraph[Hex](t, g, pairs)
+}
+
+func TestWaypointGraph(t *testing.T) {
+	rnd := rand.New(rand.NewSource(10))
+	g := &WaypointGraph{}
+	for i := 0; i < 60; i++ {
+		g.AddPoint(Waypoint{X: rnd.Float64() * 50, Y: rnd.Float64() * 50})
+	}
+	for i := 0; i < 120; i++ {
+		g.Connect(rnd.Intn(60), rnd.Intn(60))
+	}
+	pairs := make([][2]int, 300)
+	for i := range pairs {
+		pairs[i] = [2]int{rnd.Intn(60), rnd.Intn(60)}
+	}
+	checkGraph[int](t, g, pairs)
+}
+
+// GridGraph上的寻路成本与持有读锁时的Dijkstra一致
+func TestGridGraph(t *testing.T) {
+	rnd := rand.New(rand.NewSource(11))
+	r, err := NewAStar(randomMap(rnd, 20, 20, 0.2, true), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 100; i++ {
+		start, end := randomWalkable(rnd, r), randomWalkable(rnd, r)
+		// 包装后没有RLock、RUnlock，需要自己持有读锁
+		r.Grid().RLock()
+		want, wantErr := FindPathGraph[Point](zeroHeuristic[Point]{r.Graph()}, start, end)
+		r.Grid().RUnlock()
+		got, err := FindPathGraph(r.Graph(), start, end)
+		if (wantErr == nil) != (err == nil) {
+			t.Fatalf("%v -> %v: %v, %v", start, end, wantErr, err)
+		}
+		if err != nil {
+			continue
+		}
+		if got.Cost != want.Cost {
+			t.Fatalf("%v -> %v: cost %d, shortest %d", start, end, got.Cost, want.Cost)
+		}
+		checkPath(t, r, &Path{Points: got.Nodes, Cost: got.Cost}, start, end)
+	}
+}
+
+// GridGraph按原样使用起止点，不受SnapRadius、Redirect影响
+func TestGridGraphEndpoints(t *testing.T) {
+	mapData := [][]int{
+		{0, 0, 0, 0, 1, 0},
+		{0, 1, 0, 0, 1, 0},
+		{0, 0, 0, 0, 1, 0},
+	}
+	r, err := NewAStar(mapData, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.SnapRadius = 2
+	r.Redirect = true
+	// FindPath把被挡住的起点替换为相邻格子，把不可达的终点改为起点所在区域中的格子
+	blocked, unreachable := Point{X: 1, Y: 1}, Point{X: 5, Y: 1}
+	path, err := r.FindPath(&Node{X: blocked.X, Y: blocked.Y}, &Node{X: unreachable.X, Y: unreachable.Y})
+	if err != nil || !path.SnappedStart || !path.Redirected {
+		t.Fatalf("FindPath: %+v, %v", path, err)
+	}
+	if _, err := FindPathGraph[Point](r.Graph(), blocked, Point{X: 3, Y: 0}); !errors.Is(err, ErrNoPath) {
+		t.Errorf("blocked start: %v, want ErrNoPath", err)
+	}
+	if _, err := FindPathGraph[Point](r.Graph(), Point{X: 0, Y: 0}, unreachable); !errors.Is(err, ErrNoPath) {
+		t.Errorf("unreachable end: %v, want ErrNoPath", err)
+	}
+}
+
+// 在GridGraph上寻路的同时修改地图，用go test -race检查数据竞争
+func TestGridGraphConcurrentEdits(t *testing.T) {
+	rnd := rand.New(rand.NewSource(14))
+	r, err := NewAStar(randomMap(rnd, 30, 30, 0.2, true), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan struct{})
+	var edits sync.WaitGroup
+	edits.Add(1)
+	go func() {
+		defer edits.Done()
+		rnd := rand.New(rand.NewSource(15))
+		for {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			r.Grid().SetWalkable(rnd.Intn(30), rnd.Intn(30), rnd.Intn(3) != 0)
+		}
+	}()
+	var searches sync.WaitGroup
+	errs := make(chan error, 4)
+	for w := 0; w < 4; w++ {
+		searches.Add(1)
+		go func(seed int64) {
+			defer searches.Done()
+			rnd := rand.New(rand.NewSource(seed))
+			for i := 0; i < 50; i++ {
+				start, end := Point{X: rnd.Intn(30), Y: rnd.Intn(30)}, Point{X: rnd.Intn(30), Y: rnd.Intn(30)}
+				path, err := FindPathGraph[Point](r.Graph(), start, end)
+				if err == nil && (path.Nodes[0] != start || path.Nodes[len(path.Nodes)-1] != end) {
+					errs <- fmt.Errorf("path %v does not run from %v to %v", path.Nodes, start, end)
+					return
+				}
+				if err != nil && !errors.Is(err, ErrNoPath) {
+					errs <- err
+					return
+				}
+			}
+		}(int64(200 + w))
+	}
+	searches.Wait()
+	close(done)
+	edits.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,45 @@
+package astar
+
+// 开放列表（二叉堆）
+// 按F从小到大排列，F相同时H小的优先，再相同按坐标保证结果稳定
+type nodeHeap []*Node
+
+func (h nodeHeap) Len() int {
+	return len(h)
+}
+
+func (h nodeHeap) Less(i, j int) bool {
+	a, b := h[i], h[j]
+	if a.F != b.F {
+		return a.F < b.F
+	}
+	if a.H != b.H {
+		return a.H < b.H
+	}
+	if a.Y != b.Y {
+		return a.Y < b.Y
+	}
+	return a.X < b.X
+}
+
+func (h nodeHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *nodeHeap) Push(x any) {
+	node := x.(*Node)
+	node.index = len(*h)
+	*h = append(*h, node)
+}
+
+func (h *nodeHeap) Pop() any {
+	s := *h
+	n := len(s) - 1
+	node := s[n]
+	s[n] = nil
+	node.index = -1
+	*h = s[:n]
+	return node
+}
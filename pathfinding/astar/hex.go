@@ -0,0 +1,82 @@
+package astar
+
+import "fmt"
+
+/*
+六边形地图
+使用轴向坐标(Q,R)，第三个坐标S=-Q-R，两个格子的距离为三个坐标差的最大值
+每个格子有6个相邻格子，移动成本都相同
+*/
+
+// 六边形格子的轴向坐标
+type Hex struct {
+	Q int
+	R int
+}
+
+// 6个相邻方向
+var hexDirections = []Hex{{1, 0}, {1, -1}, {0, -1}, {-1, 0}, {-1, 1}, {0, 1}}
+
+// 以(0,0)为中心的六边形地图
+type HexGraph struct {
+	// 地图半径，到中心的距离不超过半径的格子在地图内
+	Radius int
+	// 障碍
+	Obstacles map[Hex]bool
+	// 格子的成本倍率（百分比），未设置时为COST_RATE_NORMAL
+	rates map[Hex]int
+	// 最低的成本倍率，用于缩放启发值
+	minRate int
+}
+
+func NewHexGraph(radius int) *HexGraph {
+	return &HexGraph{
+		Radius:    radius,
+		Obstacles: make(map[Hex]bool),
+		rates:     make(map[Hex]int),
+		minRate:   COST_RATE_NORMAL,
+	}
+}
+
+// 设置格子的成本倍率，倍率必须为正数
+func (g *HexGraph) SetRate(h Hex, rate int) error {
+	if rate <= 0 {
+		return fmt.Errorf("%w: cost rate %d at %d,%d must be positive", ErrInvalidMap, rate, h.Q, h.R)
+	}
+	g.rates[h] = rate
+	g.minRate = min(g.minRate, rate)
+	return nil
+}
+
+func (g *HexGraph) Neighbors(h Hex) []Hex {
+	neighbors := make([]Hex, 0, len(hexDirections))
+	for _, d := range hexDirections {
+		next := Hex{Q: h.Q + d.Q, R: h.R + d.R}
+		if hexDistance(next, Hex{}) <= g.Radius && !g.Obstacles[next] {
+			neighbors = append(neighbors, next)
+		}
+	}
+	return neighbors
+}
+
+// 按进入格子的成本倍率计算，与方格地图相同地向上取整，每一步不低于按倍率缩放的距离，启发值不会高估
+func (g *HexGraph) Cost(h, next Hex) int {
+	return (COST_STRAIGHT*g.rate(next) + COST_RATE_NORMAL - 1) / COST_RATE_NORMAL
+}
+
+// 按地图中最低的成本倍率缩放，保证不高估
+func (g *HexGraph) Heuristic(h, end Hex) int {
+	return hexDistance(h, end) * COST_STRAIGHT * g.minRate / COST_RATE_NORMAL
+}
+
+func (g *HexGraph) rate(h Hex) int {
+	if rate, ok := g.rates[h]; ok {
+		return rate
+	}
+	return COST_RATE_NORMAL
+}
+
+func hexDistance(a, b Hex) int {
+	dq, dr := a.Q-b.Q, a.R-b.R
+	return max(abs(dq), abs(dr), abs(dq+dr))
+}
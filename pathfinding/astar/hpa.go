@@ -0,0 +1,574 @@
+package astar
+
+import (
+	"container/heap"
+	"context"
+	"fmt"
+	"sync"
+)
+
+/*
+分层寻路（HPA*）
+把地图划分成Size*Size的区块，相邻区块边界上连续可行的格子组成入口，
+入口两侧各生成一个抽象节点，抽象节点之间的边：
+  区块间：入口两侧的节点，成本为跨过边界的一步
+  区块内：同一区块的节点两两之间，成本为限制在区块内的A*最短路径
+两侧格子都是障碍、只能斜向跨过边界的位置也作为入口（只有MOVEMENT_EIGHT允许），
+区块四角的斜向入口连接对角的区块，由左侧区块的右边界负责
+查询时把起止点临时连到所在区块的抽象节点，在抽象图上搜索后拼接每条边的细化路径
+格子变化时只标记所在区块，下次查询时重建它周围的边界入口，以及入口或格子有变化的区块的内部边
+收到的变化不全（地图版本不符）或移动方式改变时重建整个抽象图
+*/
+
+// 入口长度不小于该值时在两端各放一个节点，否则只在中间放一个
+const hpaWideEntrance = 6
+
+// 抽象图的节点
+type hpaNode struct {
+	Point
+	cluster int
+	// 区块间、区块内的边
+	inter []hpaEdge
+	intra []hpaEdge
+}
+
+// 抽象图的边
+type hpaEdge struct {
+	to   int
+	cost int
+	// 细化路径，包含两端
+	points []Point
+}
+
+// 分层寻路，可被多个goroutine同时调用
+type HPA struct {
+	astar *AStar
+	// 区块边长
+	Size int
+	// 区块行列数
+	rows int
+	cols int
+	mu   sync.RWMutex
+	// 抽象节点
+	nodes  map[int]*hpaNode
+	nextID int
+	// 区块包含的抽象节点
+	clusterNodes map[int][]int
+	// 区块右侧（0）、下侧（1）边界上的抽象节点
+	borders map[[2]int][]int
+	// 订阅的地图和取消订阅的函数
+	grid   *Grid
+	cancel func()
+	// 有格子变化、下次查询时重建的区块
+	dirty map[int]bool
+	// 为true时下次查询重建整个抽象图
+	stale bool
+	// 抽象图对应的地图版本、之后收到的变化数量和移动方式
+	version  int
+	received int
+	movement int
+}
+
+// 按区块边长构建抽象图，并订阅地图变化
+func (r *AStar) NewHPA(size int) (*HPA, error) {
+	if size < 2 {
+		return nil, fmt.Errorf("cluster size %d is too small", size)
+	}
+	h := &HPA{
+		astar: r,
+		Size:  size,
+		dirty: make(map[int]bool),
+	}
+	h.bind(r.grid)
+	r.hpaMu.Lock()
+	if r.hpas == nil {
+		r.hpas = make(map[*HPA]bool)
+	}
+	r.hpas[h] = true
+	r.hpaMu.Unlock()
+	r.grid.RLock()
+	defer r.grid.RUnlock()
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.refresh()
+	return h, nil
+}
+
+// 订阅地图变化，下次查询时重建整个抽象图，调用方不能持有地图的锁
+// 先订阅再标记重建，之间的变化不会丢失
+func (h *HPA) bind(g *Grid) {
+	cancel := g.Subscribe(func(change Change) {
+		h.onChange(g, change)
+	})
+	h.mu.Lock()
+	old := h.cancel
+	h.grid, h.cancel = g, cancel
+	h.stale = true
+	h.mu.Unlock()
+	// 取消旧的订阅需要旧地图的写锁，不能持有h.mu
+	if old != nil {
+		old()
+	}
+}
+
+// 停止跟随地图变化
+func (h *HPA) Close() {
+	h.astar.hpaMu.Lock()
+	delete(h.astar.hpas, h)
+	h.astar.hpaMu.Unlock()
+	h.mu.Lock()
+	cancel := h.cancel
+	h.mu.Unlock()
+	cancel()
+}
+
+// 分层寻路，路径接近最短路径但不保证最短
+func (h *HPA) FindPath(start, end *Node) (*Path, error) {
+	h.astar.grid.RLock()
+	defer h.astar.grid.RUnlock()
+	h.mu.RLock()
+	if !h.current() {
+		h.mu.RUnlock()
+		h.mu.Lock()
+		h.refresh()
+		h.mu.Unlock()
+		h.mu.RLock()
+	}
+	defer h.mu.RUnlock()
+	if err := checkEndpoints(h.astar.grid, start, end); err != nil {
+		return nil, err
+	}
+	s := h.astar.Acquire()
+	defer h.astar.Release(s)
+	from, to := Point{X: start.X, Y: start.Y}, Point{X: end.X, Y: end.Y}
+	expanded := 0
+	// 起止点连到所在区块的抽象节点
+	startEdges := h.connect(s, from, true, &expanded)
+	endEdges := h.connect(s, to, false, &expanded)
+	// 起止点在同一区块时，区块内直接可达的路径也是候选
+	var direct *hpaEdge
+	if h.cluster(from.X, from.Y) == h.cluster(to.X, to.Y) {
+		if path := h.searchInCluster(s, from, to, h.cluster(from.X, from.Y)); path != nil {
+			expanded += path.Expanded
+			direct = &hpaEdge{to: hpaEnd, cost: path.Cost, points: path.Points}
+		}
+	}
+	edges, n := h.abstractSearch(from, to, startEdges, endEdges, direct)
+	expanded += n
+	if edges == nil {
+		return nil, ErrNoPath
+	}
+	path := &Path{
+		Points:   []Point{from},
+		Expanded: expanded,
+	}
+	for _, e := range edges {
+		path.Cost += e.cost
+		path.Points = append(path.Points, e.points[1:]...)
+	}
+	return path, nil
+}
+
+// 查询时起点、终点的临时编号
+const (
+	hpaStart = -1
+	hpaEnd   = -2
+)
+
+// 把坐标连到所在区块的抽象节点，out为true时边从坐标出发，否则指向坐标
+func (h *HPA) connect(s *Searcher, p Point, out bool, expanded *int) map[int]hpaEdge {
+	c := h.cluster(p.X, p.Y)
+	edges := make(map[int]hpaEdge)
+	for _, id := range h.clusterNodes[c] {
+		node := h.nodes[id]
+		from, to := p, node.Point
+		if !out {
+			from, to = node.Point, p
+		}
+		path := h.searchInCluster(s, from, to, c)
+		if path == nil {
+			continue
+		}
+		*expanded += path.Expanded
+		target := id
+		if !out {
+			target = hpaEnd
+		}
+		edges[id] = hpaEdge{to: target, cost: path.Cost, points: path.Points}
+	}
+	return edges
+}
+
+// 抽象图上的A*，返回从起点到终点依次经过的边
+func (h *HPA) abstractSearch(from, to Point, startEdges, endEdges map[int]hpaEdge, direct *hpaEdge) ([]hpaEdge, int) {
+	type visit struct {
+		g      int
+		parent int
+		edge   hpaEdge
+		closed bool
+	}
+	end := &Node{X: to.X, Y: to.Y}
+	heuristic := func(p Point) int {
+		return h.astar.grid.ScaleHeuristic(h.astar.heuristic(&Node{X: p.X, Y: p.Y}, end))
+	}
+	visits := map[int]*visit{hpaStart: {}}
+	queue := &hpaQueue{}
+	heap.Push(queue, hpaItem{id: hpaStart, f: heuristic(from)})
+	expanded := 0
+	for queue.Len() > 0 {
+		item := heap.Pop(queue).(hpaItem)
+		v := visits[item.id]
+		if v.closed {
+			continue
+		}
+		v.closed = true
+		expanded++
+		if item.id == hpaEnd {
+			edges := make([]hpaEdge, 0)
+			for id := hpaEnd; id != hpaStart; id = visits[id].parent {
+				edges = append(edges, visits[id].edge)
+			}
+			for i, j := 0, len(edges)-1; i < j; i, j = i+1, j-1 {
+				edges[i], edges[j] = edges[j], edges[i]
+			}
+			return edges, expanded
+		}
+		// 当前节点的出边
+		out := make([]hpaEdge, 0)
+		if item.id == hpaStart {
+			for _, e := range startEdges {
+				out = append(out, e)
+			}
+			if direct != nil {
+				out = append(out, *direct)
+			}
+		} else {
+			node := h.nodes[item.id]
+			out = append(out, node.inter...)
+			out = append(out, node.intra...)
+			if e, ok := endEdges[item.id]; ok {
+				out = append(out, e)
+			}
+		}
+		for _, e := range out {
+			g := v.g + e.cost
+			next, ok := visits[e.to]
+			if ok && (next.closed || g >= next.g) {
+				continue
+			}
+			visits[e.to] = &visit{g: g, parent: item.id, edge: e}
+			p := to
+			if e.to != hpaEnd {
+				p = h.nodes[e.to].Point
+			}
+			heap.Push(queue, hpaItem{id: e.to, f: g + heuristic(p)})
+		}
+	}
+	return nil, expanded
+}
+
+// 格子变化时只标记所在区块，不读取地图
+func (h *HPA) onChange(g *Grid, change Change) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	// 已经取消的订阅，或重建时已经包含了这个变化
+	if g != h.grid || change.Version <= h.version {
+		return
+	}
+	h.received++
+	h.dirty[h.cluster(change.X, change.Y)] = true
+}
+
+// 抽象图与地图一致，调用方持有地图的读锁和h.mu
+func (h *HPA) current() bool {
+	g := h.astar.grid
+	return !h.stale && len(h.dirty) == 0 && h.received == 0 && h.version == g.Version() && h.movement == h.astar.Movement
+}
+
+// 按标记的区块更新抽象图，需要时全部重建，调用方持有地图的读锁和h.mu的写锁
+func (h *HPA) refresh() {
+	g := h.astar.grid
+	if h.version+h.received != g.Version() || h.movement != h.astar.Movement {
+		h.stale = true
+	}
+	s := h.astar.Acquire()
+	defer h.astar.Release(s)
+	changed := make(map[int]bool)
+	if h.stale {
+		h.rows = (g.Rows + h.Size - 1) / h.Size
+		h.cols = (g.Cols + h.Size - 1) / h.Size
+		h.nodes = make(map[int]*hpaNode)
+		h.clusterNodes = make(map[int][]int)
+		h.borders = make(map[[2]int][]int)
+		h.movement = h.astar.Movement
+		for c := 0; c < h.rows*h.cols; c++ {
+			h.buildBorder(c, 0, changed)
+			h.buildBorder(c, 1, changed)
+			changed[c] = true
+		}
+	} else {
+		for c := range h.dirty {
+			h.rebuildBorders(c, changed)
+			changed[c] = true
+		}
+	}
+	for c := range changed {
+		h.buildIntra(s, c)
+	}
+	h.dirty = make(map[int]bool)
+	h.stale = false
+	h.version, h.received = g.Version(), 0
+}
+
+// 重建区块c的格子可能影响的边界：
+// 自己和左、上区块的边界，以及四角由左侧区块右边界负责的斜向入口
+func (h *HPA) rebuildBorders(c int, changed map[int]bool) {
+	cx, cy := c%h.cols, c/h.cols
+	for dy := -1; dy <= 1; dy++ {
+		y := cy + dy
+		if y < 0 || y >= h.rows {
+			continue
+		}
+		for dx := -1; dx <= 0; dx++ {
+			if x := cx + dx; x >= 0 {
+				h.buildBorder(y*h.cols+x, 0, changed)
+			}
+		}
+		if dy <= 0 {
+			h.buildBorder(y*h.cols+cx, 1, changed)
+		}
+	}
+}
+
+// 重建区块c右侧（dir为0）或下侧（dir为1）边界上的入口，入口有变化的区块加入changed
+func (h *HPA) buildBorder(c, dir int, changed map[int]bool) {
+	key := [2]int{c, dir}
+	cx, cy := c%h.cols, c/h.cols
+	var transitions [][2]Point
+	if dir == 0 && cx < h.cols-1 || dir == 1 && cy < h.rows-1 {
+		transitions = h.transitions(c, dir)
+	}
+	old := h.borders[key]
+	if h.sameTransitions(old, transitions) {
+		// 入口不变，只更新跨过边界的成本
+		for _, id := range old {
+			for i := range h.nodes[id].inter {
+				e := &h.nodes[id].inter[i]
+				e.cost = h.astar.grid.StepCost(e.points[0].X, e.points[0].Y, e.points[1].X, e.points[1].Y)
+			}
+		}
+		return
+	}
+	for _, id := range old {
+		node := h.nodes[id]
+		h.clusterNodes[node.cluster] = removeID(h.clusterNodes[node.cluster], id)
+		changed[node.cluster] = true
+		delete(h.nodes, id)
+	}
+	delete(h.borders, key)
+	for _, p := range transitions {
+		h.addTransition(key, p, changed)
+	}
+}
+
+// 区块c右侧（dir为0）或下侧（dir为1）边界上的入口，每个入口为边界两侧的格子
+func (h *HPA) transitions(c, dir int) [][2]Point {
+	g := h.astar.grid
+	area := h.rect(c)
+	// 沿边界扫描，a为区块内一侧的格子，b为相邻区块一侧的格子，
+	// step为沿边界前进一格的方向
+	var length int
+	var cells func(i int) [2]Point
+	var step Point
+	if dir == 0 {
+		length = area.y1 - area.y0 + 1
+		cells = func(i int) [2]Point {
+			return [2]Point{{X: area.x1, Y: area.y0 + i}, {X: area.x1 + 1, Y: area.y0 + i}}
+		}
+		step = Point{Y: 1}
+	} else {
+		length = area.x1 - area.x0 + 1
+		cells = func(i int) [2]Point {
+			return [2]Point{{X: area.x0 + i, Y: area.y1}, {X: area.x0 + i, Y: area.y1 + 1}}
+		}
+		step = Point{X: 1}
+	}
+	open := func(i int) bool {
+		p := cells(i)
+		return g.IsWalkable(p[0].X, p[0].Y) && g.IsWalkable(p[1].X, p[1].Y)
+	}
+	var transitions [][2]Point
+	for i := 0; i < length; i++ {
+		if !open(i) {
+			continue
+		}
+		j := i
+		for j+1 < length && open(j+1) {
+			j++
+		}
+		if j-i+1 >= hpaWideEntrance {
+			transitions = append(transitions, cells(i), cells(j))
+		} else {
+			transitions = append(transitions, cells((i+j)/2))
+		}
+		i = j
+	}
+	// 斜向入口：两侧的格子可行时可以经过直线入口绕过去，只有两侧都是障碍时才需要
+	for i := 0; i < length; i++ {
+		a := cells(i)[0]
+		for _, k := range []int{-1, 1} {
+			// 下边界不负责区块四角
+			if dir == 1 && (i+k < 0 || i+k >= length) {
+				continue
+			}
+			d := cells(i)[1]
+			b := Point{X: d.X + k*step.X, Y: d.Y + k*step.Y}
+			dx, dy := b.X-a.X, b.Y-a.Y
+			if !g.InBounds(b.X, b.Y) || g.IsWalkable(a.X+dx, a.Y) || g.IsWalkable(a.X, a.Y+dy) {
+				continue
+			}
+			if g.IsWalkable(a.X, a.Y) && g.CanMove(h.movement, a.X, a.Y, dx, dy) {
+				transitions = append(transitions, [2]Point{a, b})
+			}
+		}
+	}
+	return transitions
+}
+
+// 边界上已有的抽象节点是否与入口一致
+func (h *HPA) sameTransitions(ids []int, transitions [][2]Point) bool {
+	if len(ids) != 2*len(transitions) {
+		return false
+	}
+	for i, p := range transitions {
+		if h.nodes[ids[2*i]].Point != p[0] || h.nodes[ids[2*i+1]].Point != p[1] {
+			return false
+		}
+	}
+	return true
+}
+
+// 在入口两侧各加一个抽象节点并相互连接
+func (h *HPA) addTransition(key [2]int, p [2]Point, changed map[int]bool) {
+	a, b := p[0], p[1]
+	ca, cb := h.cluster(a.X, a.Y), h.cluster(b.X, b.Y)
+	ida, idb := h.addNode(a, ca), h.addNode(b, cb)
+	changed[ca], changed[cb] = true, true
+	h.nodes[ida].inter = append(h.nodes[ida].inter, hpaEdge{
+		to:     idb,
+		cost:   h.astar.grid.StepCost(a.X, a.Y, b.X, b.Y),
+		points: []Point{a, b},
+	})
+	h.nodes[idb].inter = append(h.nodes[idb].inter, hpaEdge{
+		to:     ida,
+		cost:   h.astar.grid.StepCost(b.X, b.Y, a.X, a.Y),
+		points: []Point{b, a},
+	})
+	h.borders[key] = append(h.borders[key], ida, idb)
+}
+
+func (h *HPA) addNode(p Point, c int) int {
+	id := h.nextID
+	h.nextID++
+	h.nodes[id] = &hpaNode{Point: p, cluster: c}
+	h.clusterNodes[c] = append(h.clusterNodes[c], id)
+	return id
+}
+
+// 重建区块内抽象节点两两之间的边
+func (h *HPA) buildIntra(s *Searcher, c int) {
+	ids := h.clusterNodes[c]
+	for _, id := range ids {
+		h.nodes[id].intra = h.nodes[id].intra[:0]
+	}
+	for _, a := range ids {
+		for _, b := range ids {
+			if a == b {
+				continue
+			}
+			path := h.searchInCluster(s, h.nodes[a].Point, h.nodes[b].Point, c)
+			if path == nil {
+				continue
+			}
+			h.nodes[a].intra = append(h.nodes[a].intra, hpaEdge{
+				to:     b,
+				cost:   path.Cost,
+				points: path.Points,
+			})
+		}
+	}
+}
+
+// 限制在区块内的A*，调用方持有地图的读锁
+// 结果会缓存为抽象图的边，不受调用方的预算和膨胀系数影响，找不到完整路径时返回空
+func (h *HPA) searchInCluster(s *Searcher, from, to Point, c int) *Path {
+	area := h.rect(c)
+	s.limit = &area
+	s.exact = true
+	defer func() {
+		s.limit = nil
+		s.exact = false
+	}()
+	node, err := s.find(context.Background(), &Node{X: from.X, Y: from.Y}, &Node{X: to.X, Y: to.Y})
+	if err != nil || node == nil {
+		return nil
+	}
+	return newPath(node, len(s.closeList))
+}
+
+// 坐标所在的区块
+func (h *HPA) cluster(x, y int) int {
+	return (y/h.Size)*h.cols + x/h.Size
+}
+
+// 区块的范围
+func (h *HPA) rect(c int) rect {
+	cx, cy := c%h.cols, c/h.cols
+	return rect{
+		x0: cx * h.Size,
+		y0: cy * h.Size,
+		x1: min((cx+1)*h.Size, h.astar.grid.Cols) - 1,
+		y1: min((cy+1)*h.Size, h.astar.grid.Rows) - 1,
+	}
+}
+
+func removeID(ids []int, id int) []int {
+	for i, v := range ids {
+		if v == id {
+			return append(ids[:i], ids[i+1:]...)
+		}
+	}
+	return ids
+}
+
+// 抽象图搜索的优先队列
+type hpaItem struct {
+	id int
+	f  int
+}
+
+type hpaQueue []hpaItem
+
+func (q hpaQueue) Len() int {
+	return len(q)
+}
+
+func (q hpaQueue) Less(i, j int) bool {
+	return q[i].f < q[j].f
+}
+
+func (q hpaQueue) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+}
+
+func (q *hpaQueue) Push(x any) {
+	*q = append(*q, x.(hpaItem))
+}
+
+func (q *hpaQueue) Pop() any {
+	s := *q
+	n := len(s) - 1
+	item := s[n]
+	*q = s[:n]
+	return item
+}
@@ -0,0 +1,165 @@
+package astar
+
+import (
+	"math/rand"
+	"testing"
+)
+
+// 每种移动方式下，分层寻路与A*对同一组查询的可达性一致，路径完整合法，成本不低于A*
+func TestHPAMatchesAStar(t *testing.T) {
+	rnd := rand.New(rand.NewSource(3))
+	r, err := NewAStar(randomMap(rnd, 40, 40, 0.3, true), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h, err := r.NewHPA(8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+	for i := 0; i < 800; i++ {
+		// 移动方式改变时重建抽象图
+		r.Movement = []int{MOVEMENT_EIGHT, MOVEMENT_EIGHT_NO_CORNER_CUT, MOVEMENT_EIGHT_NO_OBSTACLE, MOVEMENT_FOUR}[i/200]
+		start, end := randomWalkable(rnd, r), randomWalkable(rnd, r)
+		want, wantErr := r.FindPath(&Node{X: start.X, Y: start.Y}, &Node{X: end.X, Y: end.Y})
+		path, err := h.FindPath(&Node{X: start.X, Y: start.Y}, &Node{X: end.X, Y: end.Y})
+		if (err == nil) != (wantErr == nil) {
+			t.Fatalf("movement %d %v -> %v: hpa err %v, astar err %v", r.Movement, start, end, err, wantErr)
+		}
+		if err != nil {
+			continue
+		}
+		checkPath(t, r, path, start, end)
+		if path.Cost < want.Cost {
+			t.Fatalf("%v -> %v: hpa cost %d below optimal %d", start, end, path.Cost, want.Cost)
+		}
+	}
+}
+
+// 调用方的预算和膨胀系数不影响区块内的边，分层寻路的路径总是完整合法
+func TestHPAIgnoresSearchBudget(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	mapData := randomMap(rnd, 40, 40, 0.2, false)
+	r, err := NewAStar(mapData, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.MaxExpanded = 5
+	r.Weight = 3
+	h, err := r.NewHPA(8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+	for i := 0; i < 200; i++ {
+		start, end := randomWalkable(rnd, r), randomWalkable(rnd, r)
+		path, err := h.FindPath(&Node{X: start.X, Y: start.Y}, &Node{X: end.X, Y: end.Y})
+		if r.Connected(start, end) != (err == nil) {
+			t.Fatalf("%v -> %v: connected %v, err %v", start, end, r.Connected(start, end), err)
+		}
+		if err == nil {
+			checkPath(t, r, path, start, end)
+		}
+	}
+}
+
+// 修改地图后分层寻路与连通性一致
+func TestHPAFollowsGrid(t *testing.T) {
+	rnd := rand.New(rand.NewSource(2))
+	r, err := NewAStar(randomMap(rnd, 30, 30, 0.2, false), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h, err := r.NewHPA(6)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+	for i := 0; i < 200; i++ {
+		if err := r.Grid().SetWalkable(rnd.Intn(30), rnd.Intn(30), rnd.Intn(3) != 0); err != nil {
+			t.Fatal(err)
+		}
+		start, end := randomWalkable(rnd, r), randomWalkable(rnd, r)
+		path, err := h.FindPath(&Node{X: start.X, Y: start.Y}, &Node{X: end.X, Y: end.Y})
+		if r.Connected(start, end) != (err == nil) {
+			t.Fatalf("%v -> %v: connected %v, err %v", start, end, r.Connected(start, end), err)
+		}
+		if err == nil {
+			checkPath(t, r, path, start, end)
+		}
+	}
+}
+
+// 只能斜向跨过区块边界的位置也是入口
+func TestHPADiagonalBorder(t *testing.T) {
+	mapData := [][]int{
+		{0, 0, 0, 0, 1, 1, 1, 1},
+		{1, 1, 1, 0, 1, 1, 1, 1},
+		{1, 1, 1, 1, 0, 0, 0, 0},
+		{1, 1, 1, 1, 1, 1, 1, 1},
+	}
+	r, err := NewAStar(mapData, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h, err := r.NewHPA(4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+	start, end := Point{X: 0, Y: 0}, Point{X: 7, Y: 2}
+	want, err := r.FindPath(&Node{X: start.X, Y: start.Y}, &Node{X: end.X, Y: end.Y})
+	if err != nil {
+		t.Fatal(err)
+	}
+	path, err := h.FindPath(&Node{X: start.X, Y: start.Y}, &Node{X: end.X, Y: end.Y})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkPath(t, r, path, start, end)
+	if path.Cost != want.Cost {
+		t.Errorf("hpa cost %d, astar cost %d", path.Cost, want.Cost)
+	}
+}
+
+// 修改格子只标记区块，查询时才重建；Init更换地图后跟随新地图
+func TestHPAFollowsInit(t *testing.T) {
+	rnd := rand.New(rand.NewSource(4))
+	r, err := NewAStar(randomMap(rnd, 20, 20, 0.2, false), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h, err := r.NewHPA(5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+	old := r.Grid()
+	r.Rows, r.Cols = 24, 30
+	if err := r.Init(randomMap(rnd, 24, 30, 0.2, false)); err != nil {
+		t.Fatal(err)
+	}
+	// 旧地图的变化不再影响分层寻路
+	old.SetWalkable(0, 0, false)
+	for i := 0; i < 200; i++ {
+		x, y := rnd.Intn(30), rnd.Intn(24)
+		walkable := !r.Grid().IsWalkable(x, y)
+		if err := r.Grid().SetWalkable(x, y, walkable); err != nil {
+			t.Fatal(err)
+		}
+		h.mu.RLock()
+		dirty := len(h.dirty)
+		h.mu.RUnlock()
+		if dirty != 1 {
+			t.Fatalf("edit %d: %d dirty clusters before the query, want 1", i, dirty)
+		}
+		start, end := randomWalkable(rnd, r), randomWalkable(rnd, r)
+		path, err := h.FindPath(&Node{X: start.X, Y: start.Y}, &Node{X: end.X, Y: end.Y})
+		if r.Connected(start, end) != (err == nil) {
+			t.Fatalf("%v -> %v: connected %v, err %v", start, end, r.Connected(start, end), err)
+		}
+		if err == nil {
+			checkPath(t, r, path, start, end)
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package astar
+
+import (
+	"container/heap"
+	"fmt"
+)
+
+/*
+多目标寻路
+从起点搜索到一组终点中成本最低的一个，启发值取到各终点启发值的最小值，
+终点较多时逐个计算启发值的开销超过收益，退化为Dijkstra（启发值为0）
+*/
+
+// 终点数量超过该值时不使用启发值
+const nearestHeuristicLimit = 16
+
+// 寻路到goals中成本最低的终点，返回路径和所选终点在goals中的下标
+// 起点和被挡住的终点按SnapRadius替换为附近的可行格子，越界、被挡住和与起点不连通的终点会被忽略，
+// 没有剩下的终点时，有终点不连通返回ErrNoPath，有终点被挡住返回ErrEndBlocked，全部越界时返回ErrOutOfBounds
+// 多个终点时不使用Redirect
+func (r *AStar) FindPathNearest(start *Node, goals []*Node) (*Path, int, error) {
+	r.grid.RLock()
+	defer r.grid.RUnlock()
+	s := r.Acquire()
+	defer r.Release(s)
+	start, snappedStart := s.snap(start)
+	if !r.grid.InBounds(start.X, start.Y) {
+		return nil, -1, fmt.Errorf("start %d,%d: %w", start.X, start.Y, ErrOutOfBounds)
+	}
+	if !r.grid.IsWalkable(start.X, start.Y) {
+		return nil, -1, fmt.Errorf("start %d,%d: %w", start.X, start.Y, ErrStartBlocked)
+	}
+	// 节点下标到终点下标，重复的终点取第一个
+	targets := make(map[int]int, len(goals))
+	// 被替换过的终点
+	snapped := make(map[int]bool)
+	walkable := make([]*Node, 0, len(goals))
+	blocked, unreachable := false, false
+	c := r.componentsOf(0)
+	from := Point{X: start.X, Y: start.Y}
+	for i, goal := range goals {
+		if !r.grid.InBounds(goal.X, goal.Y) {
+			continue
+		}
+		goal, snappedGoal := s.snap(goal)
+		if !r.grid.IsWalkable(goal.X, goal.Y) {
+			blocked = true
+			continue
+		}
+		// 与起点不连通的终点不必搜索
+		if c != nil && !c.connected(r.Movement, from, Point{X: goal.X, Y: goal.Y}) {
+			unreachable = true
+			continue
+		}
+		id := goal.X*r.grid.Rows + goal.Y
+		if _, ok := targets[id]; !ok {
+			targets[id] = i
+			snapped[id] = snappedGoal
+			walkable = append(walkable, goal)
+		}
+	}
+	if len(walkable) == 0 {
+		if unreachable {
+			return nil, -1, ErrNoPath
+		}
+		if !blocked && len(goals) > 0 {
+			return nil, -1, fmt.Errorf("all %d goals: %w", len(goals), ErrOutOfBounds)
+		}
+		return nil, -1, ErrEndBlocked
+	}
+	if len(walkable) > nearestHeuristicLimit {
+		walkable = nil
+	}
+	node := s.findNearest(start, targets, walkable)
+	if node == nil {
+		return nil, -1, ErrNoPath
+	}
+	id := node.X*r.grid.Rows + node.Y
+	path := newPath(node, len(s.closeList))
+	path.SnappedStart = snappedStart
+	path.SnappedEnd = snapped[id]
+	return path, targets[id], nil
+}
+
+// 搜索到任意一个终点，返回该终点节点，并作为本次搜索的终点
+// goals为空时不使用启发值
+func (r *Searcher) findNearest(start *Node, targets map[int]int, goals []*Node) *Node {
+	r.reset()
+	r.start = r.getNode(start.X, start.Y)
+	r.end = nil
+	r.start.H = r.nearestHeuristic(r.start, goals)
+	r.start.F = r.start.H
+	r.openListAppend(r.start)
+	for len(r.openList) > 0 {
+		node := r.openListPop()
+		if _, ok := targets[node.X*r.astar.grid.Rows+node.Y]; ok {
+			r.end = node
+			return node
+		}
+		for _, neighbor := range r.neighbors(node) {
+			if neighbor.isClosed() {
+				continue
+			}
+			g := node.G + r.cost(node, neighbor)
+			if !neighbor.isOpened() || g < neighbor.G {
+				neighbor.G = g
+				neighbor.H = r.nearestHeuristic(neighbor, goals)
+				neighbor.F = neighbor.G + r.astar.inflate(neighbor.H)
+				neighbor.Parent = node
+				if !neighbor.isOpened() {
+					r.openListAppend(neighbor)
+				} else {
+					heap.Fix(&r.openList, neighbor.index)
+				}
+			}
+		}
+		r.closeListAppend(node)
+	}
+	return nil
+}
+
+// 到各终点启发值的最小值
+func (r *Searcher) nearestHeuristic(node *Node, goals []*Node) int {
+	h := 0
+	for i, goal := range goals {
+		v := r.astar.grid.ScaleHeuristic(r.astar.heuristic(node, goal))
+		if i == 0 || v < h {
+			h = v
+		}
+	}
+	return h
+}
@@ -0,0 +1,150 @@
+package astar
+
+import (
+	"errors"
+	"math/rand"
+	"testing"
+)
+
+// 多个终点时到达成本最低的终点，终点超过nearestHeuristicLimit时按Dijkstra搜索结果相同
+func TestFindPathNearest(t *testing.T) {
+	rnd := rand.New(rand.NewSource(18))
+	r, err := NewAStar(randomMap(rnd, 20, 20, 0.2, true), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 100; i++ {
+		start := randomWalkable(rnd, r)
+		goals := make([]*Node, 0)
+		for j := 0; j < 2+i%2*nearestHeuristicLimit; j++ {
+			goals = append(goals, &Node{X: rnd.Intn(20), Y: rnd.Intn(20)})
+		}
+		best := -1
+		for _, goal := range goals {
+			if path, err := r.FindPath(&Node{X: start.X, Y: start.Y}, goal); err == nil && (best < 0 || path.Cost < best) {
+				best = path.Cost
+			}
+		}
+		path, index, err := r.FindPathNearest(&Node{X: start.X, Y: start.Y}, goals)
+		if best < 0 {
+			if err == nil {
+				t.Fatalf("%v: found goal %d, no goal is reachable", start, index)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%v: %v", start, err)
+		}
+		if path.Cost != best {
+			t.Fatalf("%v: cost %d to goal %d, cheapest %d", start, path.Cost, index, best)
+		}
+		checkPath(t, r, path, start, Point{X: goals[index].X, Y: goals[index].Y})
+	}
+}
+
+// 越界和被挡住的终点被忽略，选中的终点成本最低
+func TestFindPathNearestSkipsInvalidGoals(t *testing.T) {
+	rnd := rand.New(rand.NewSource(7))
+	r, err := NewAStar(randomMap(rnd, 20, 20, 0.2, true), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 100; i++ {
+		start := randomWalkable(rnd, r)
+		goals := []*Node{{X: -1, Y: 3}, {X: 20, Y: 20}}
+		for j := 0; j < 4; j++ {
+			goals = append(goals, &Node{X: rnd.Intn(20), Y: rnd.Intn(20)})
+		}
+		best := -1
+		for _, goal := range goals {
+			if path, err := r.FindPath(&Node{X: start.X, Y: start.Y}, goal); err == nil && (best < 0 || path.Cost < best) {
+				best = path.Cost
+			}
+		}
+		path, index, err := r.FindPathNearest(&Node{X: start.X, Y: start.Y}, goals)
+		if best < 0 {
+			if err == nil {
+				t.Fatalf("%v: found goal %d, no goal is reachable", start, index)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%v: %v", start, err)
+		}
+		goal := Point{X: goals[index].X, Y: goals[index].Y}
+		if path.Cost != best {
+			t.Fatalf("%v: cost %d to goal %d, cheapest %d", start, path.Cost, index, best)
+		}
+		checkPath(t, r, path, start, goal)
+	}
+	// 搜索的终点是选中的终点
+	s := r.Acquire()
+	defer r.Release(s)
+	start := randomWalkable(rnd, r)
+	r.Grid().RLock()
+	node := s.findNearest(&Node{X: start.X, Y: start.Y}, map[int]int{start.X*r.Rows + start.Y: 0}, nil)
+	r.Grid().RUnlock()
+	if node == nil || s.end != node {
+		t.Fatalf("search end %v, found %v", s.end, node)
+	}
+	_, _, err = r.FindPathNearest(&Node{X: 0, Y: 0}, []*Node{{X: -1, Y: 0}, {X: 0, Y: 20}})
+	if !errors.Is(err, ErrOutOfBounds) {
+		t.Fatalf("all goals out of bounds: %v", err)
+	}
+}
+
+// 设置SnapRadius后起点和终点的替换与FindPath相同，与起点不连通的终点被忽略，全部不连通时返回ErrNoPath
+func TestFindPathNearestResolve(t *testing.T) {
+	rnd := rand.New(rand.NewSource(19))
+	r, err := NewAStar(randomMap(rnd, 20, 20, 0.35, false), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Movement = MOVEMENT_FOUR
+	r.SnapRadius = 2
+	snapped, unreachable := 0, 0
+	for i := 0; i < 200; i++ {
+		start := &Node{X: rnd.Intn(20), Y: rnd.Intn(20)}
+		goals := make([]*Node, 3)
+		for j := range goals {
+			goals[j] = &Node{X: rnd.Intn(20), Y: rnd.Intn(20)}
+		}
+		var best *Path
+		noPath := false
+		for _, goal := range goals {
+			path, err := r.FindPath(start, goal)
+			if errors.Is(err, ErrNoPath) {
+				noPath = true
+			}
+			if err == nil && (best == nil || path.Cost < best.Cost) {
+				best = path
+			}
+		}
+		path, index, err := r.FindPathNearest(start, goals)
+		if best == nil {
+			if noPath && !errors.Is(err, ErrNoPath) {
+				t.Fatalf("%v: %v, want ErrNoPath", start, err)
+			}
+			if err == nil {
+				t.Fatalf("%v: found goal %d, no goal is reachable", start, index)
+			}
+			unreachable++
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%v: %v", start, err)
+		}
+		want, _ := r.FindPath(start, goals[index])
+		if path.Cost != best.Cost || path.Points[0] != best.Points[0] ||
+			path.SnappedStart != want.SnappedStart || path.SnappedEnd != want.SnappedEnd {
+			t.Fatalf("%v: %+v to goal %d, FindPath %+v", start, path, index, want)
+		}
+		checkPath(t, r, path, want.Points[0], want.Points[len(want.Points)-1])
+		if path.SnappedStart || path.SnappedEnd {
+			snapped++
+		}
+	}
+	if snapped == 0 || unreachable == 0 {
+		t.Fatalf("%d snapped and %d unreachable searches, want both", snapped, unreachable)
+	}
+}
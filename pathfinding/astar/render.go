@@ -0,0 +1,170 @@
+package astar
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+)
+
+/*
+搜索可视化
+按寻路器上一次搜索的状态绘制地图、关闭列表、开放列表和路径，不修改任何节点
+跟踪：每扩展一个节点写一行JSON，包括节点的成本和本次更新的相邻节点
+*/
+
+// 图片中每个格子的边长（像素）
+const renderCellSize = 16
+
+// 地形颜色
+var terrainColors = map[int]color.RGBA{
+	NODE_TYPE_NORMAL:   {0xff, 0xff, 0xff, 0xff},
+	NODE_TYPE_OBSTACLE: {0x33, 0x33, 0x33, 0xff},
+	NODE_TYPE_ROAD:     {0xd9, 0xc3, 0x8a, 0xff},
+	NODE_TYPE_SWAMP:    {0x8f, 0xa8, 0x5a, 0xff},
+	NODE_TYPE_WATER:    {0x7f, 0xb2, 0xe5, 0xff},
+}
+
+// 搜索状态和路径的颜色，叠加在地形上
+var (
+	closedColor = color.RGBA{0xe5, 0x5c, 0x5c, 0x66}
+	openedColor = color.RGBA{0x5c, 0xc8, 0x5c, 0x66}
+	pathColor   = color.RGBA{0x1f, 0x4e, 0xd8, 0xff}
+)
+
+// 把地图、上一次搜索的开放、关闭列表和路径写成SVG，path为空时不画路径
+func (r *Searcher) WriteSVG(w io.Writer, path *Path) error {
+	g := r.astar.grid
+	g.RLock()
+	defer g.RUnlock()
+	bw := bufio.NewWriter(w)
+	size := renderCellSize
+	fmt.Fprintf(bw, "<svg xmlns=\"http://www.w3.org/2000/svg\" width=\"%d\" height=\"%d\">\n", g.Cols*size, g.Rows*size)
+	for x := 0; x < g.Cols; x++ {
+		for y := 0; y < g.Rows; y++ {
+			fmt.Fprintf(bw, "<rect x=\"%d\" y=\"%d\" width=\"%d\" height=\"%d\" fill=\"%s\"/>\n", x*size, y*size, size, size, svgColor(terrainColors[g.TypeAt(x, y)]))
+			if c, ok := r.stateColor(x, y); ok {
+				fmt.Fprintf(bw, "<rect x=\"%d\" y=\"%d\" width=\"%d\" height=\"%d\" fill=\"%s\" fill-opacity=\"%.2f\"/>\n", x*size, y*size, size, size, svgColor(c), float64(c.A)/0xff)
+			}
+		}
+	}
+	if path != nil && len(path.Points) > 0 {
+		fmt.Fprintf(bw, "<polyline fill=\"none\" stroke=\"%s\" stroke-width=\"%d\" points=\"", svgColor(pathColor), size/4)
+		for i, p := range path.Points {
+			if i > 0 {
+				bw.WriteByte(' ')
+			}
+			fmt.Fprintf(bw, "%d,%d", p.X*size+size/2, p.Y*size+size/2)
+		}
+		bw.WriteString("\"/>\n")
+		start, end := path.Points[0], path.Points[len(path.Points)-1]
+		fmt.Fprintf(bw, "<circle cx=\"%d\" cy=\"%d\" r=\"%d\" fill=\"%s\"/>\n", start.X*size+size/2, start.Y*size+size/2, size/3, svgColor(pathColor))
+		fmt.Fprintf(bw, "<rect x=\"%d\" y=\"%d\" width=\"%d\" height=\"%d\" fill=\"%s\"/>\n", end.X*size+size/4, end.Y*size+size/4, size/2, size/2, svgColor(pathColor))
+	}
+	bw.WriteString("</svg>\n")
+	return bw.Flush()
+}
+
+// 把地图、上一次搜索的开放、关闭列表和路径写成PNG，path为空时不画路径
+func (r *Searcher) WritePNG(w io.Writer, path *Path) error {
+	g := r.astar.grid
+	g.RLock()
+	defer g.RUnlock()
+	size := renderCellSize
+	img := image.NewRGBA(image.Rect(0, 0, g.Cols*size, g.Rows*size))
+	fill := func(x, y, inset int, c color.RGBA) {
+		for px := x*size + inset; px < (x+1)*size-inset; px++ {
+			for py := y*size + inset; py < (y+1)*size-inset; py++ {
+				img.SetRGBA(px, py, c)
+			}
+		}
+	}
+	for x := 0; x < g.Cols; x++ {
+		for y := 0; y < g.Rows; y++ {
+			c := terrainColors[g.TypeAt(x, y)]
+			if state, ok := r.stateColor(x, y); ok {
+				c = blend(c, state)
+			}
+			fill(x, y, 0, c)
+		}
+	}
+	if path != nil {
+		for _, p := range path.Points {
+			fill(p.X, p.Y, size/4, pathColor)
+		}
+	}
+	return png.Encode(w, img)
+}
+
+// 格子在上一次搜索中的状态颜色，未访问时返回false
+func (r *Searcher) stateColor(x, y int) (color.RGBA, bool) {
+	node := r.visited(x, y)
+	if node == nil {
+		return color.RGBA{}, false
+	}
+	if node.isClosed() {
+		return closedColor, true
+	}
+	return openedColor, true
+}
+
+// 按透明度把c叠加到base上
+func blend(base, c color.RGBA) color.RGBA {
+	a := uint32(c.A)
+	mix := func(b, v uint8) uint8 {
+		return uint8((uint32(b)*(0xff-a) + uint32(v)*a) / 0xff)
+	}
+	return color.RGBA{mix(base.R, c.R), mix(base.G, c.G), mix(base.B, c.B), 0xff}
+}
+
+func svgColor(c color.RGBA) string {
+	return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+}
+
+// 跟踪记录，每扩展一个节点一条
+type TraceStep struct {
+	// 第几次扩展，从1开始
+	Step int `json:"step"`
+	// 被扩展的节点
+	TraceNode
+	// 本次扩展中加入开放列表或成本降低的相邻节点
+	Updated []TraceNode `json:"updated,omitempty"`
+}
+
+type TraceNode struct {
+	X int `json:"x"`
+	Y int `json:"y"`
+	G int `json:"g"`
+	H int `json:"h"`
+	F int `json:"f"`
+}
+
+// 设置跟踪输出，之后的每次搜索都把扩展过程按JSON行写入w，w为空时停止跟踪
+// 写入出错时停止跟踪
+func (r *Searcher) Trace(w io.Writer) {
+	r.trace = nil
+	if w != nil {
+		r.trace = json.NewEncoder(w)
+	}
+}
+
+// 写入一条跟踪记录
+func (r *Searcher) traceStep(node *Node, updated []*Node) {
+	step := TraceStep{
+		Step:      len(r.closeList) + 1,
+		TraceNode: traceNode(node),
+	}
+	for _, n := range updated {
+		step.Updated = append(step.Updated, traceNode(n))
+	}
+	if err := r.trace.Encode(step); err != nil {
+		r.trace = nil
+	}
+}
+
+func traceNode(node *Node) TraceNode {
+	return TraceNode{X: node.X, Y: node.Y, G: node.G, H: node.H, F: node.F}
+}
@@ -0,0 +1,136 @@
+package astar
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"image/png"
+	"strings"
+	"testing"
+)
+
+// 在带障碍的小地图上搜索一次，返回寻路器和路径
+func renderSearch(t *testing.T) (*Searcher, *Path) {
+	t.Helper()
+	mapData := [][]int{
+		{0, 0, 0, 0, 0},
+		{0, 1, 1, 1, 0},
+		{0, 0, 0, 1, 0},
+		{1, 1, 0, 0, 0},
+	}
+	r, err := NewAStar(mapData, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := r.NewSearcher()
+	path, err := s.FindPath(&Node{X: 0, Y: 2}, &Node{X: 4, Y: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s, path
+}
+
+// SVG是完整的XML，尺寸与地图一致，每个格子一个地形矩形，路径折线经过每个路径点
+func TestWriteSVG(t *testing.T) {
+	s, path := renderSearch(t)
+	var buf bytes.Buffer
+	if err := s.WriteSVG(&buf, path); err != nil {
+		t.Fatal(err)
+	}
+	var root struct {
+		Width    int        `xml:"width,attr"`
+		Height   int        `xml:"height,attr"`
+		Rects    []struct{} `xml:"rect"`
+		Polyline []struct {
+			Points string `xml:"points,attr"`
+		} `xml:"polyline"`
+	}
+	if err := xml.Unmarshal(buf.Bytes(), &root); err != nil {
+		t.Fatalf("svg is not valid xml: %v", err)
+	}
+	if root.Width != 5*renderCellSize || root.Height != 4*renderCellSize {
+		t.Errorf("size %dx%d, want %dx%d", root.Width, root.Height, 5*renderCellSize, 4*renderCellSize)
+	}
+	if len(root.Rects) < 5*4 {
+		t.Errorf("%d rects, want at least one per cell", len(root.Rects))
+	}
+	if len(root.Polyline) != 1 || len(strings.Fields(root.Polyline[0].Points)) != len(path.Points) {
+		t.Errorf("polyline %v does not follow the %d path points", root.Polyline, len(path.Points))
+	}
+}
+
+// PNG可以解码，尺寸与地图一致，路径格子画成路径颜色，未访问的障碍保留地形颜色
+func TestWritePNG(t *testing.T) {
+	s, path := renderSearch(t)
+	var buf bytes.Buffer
+	if err := s.WritePNG(&buf, path); err != nil {
+		t.Fatal(err)
+	}
+	img, err := png.Decode(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	size := renderCellSize
+	if b := img.Bounds(); b.Dx() != 5*size || b.Dy() != 4*size {
+		t.Fatalf("size %v, want %dx%d", b, 5*size, 4*size)
+	}
+	same := func(x, y int, want [4]uint8) bool {
+		r, g, b, a := img.At(x, y).RGBA()
+		return [4]uint8{uint8(r >> 8), uint8(g >> 8), uint8(b >> 8), uint8(a >> 8)} == want
+	}
+	for _, p := range path.Points {
+		if !same(p.X*size+size/2, p.Y*size+size/2, [4]uint8{pathColor.R, pathColor.G, pathColor.B, pathColor.A}) {
+			t.Errorf("path cell %v is not drawn in the path colour", p)
+		}
+	}
+	obstacle := terrainColors[NODE_TYPE_OBSTACLE]
+	if !same(0, 3*size, [4]uint8{obstacle.R, obstacle.G, obstacle.B, obstacle.A}) {
+		t.Errorf("unvisited obstacle (0,3) is not drawn in the obstacle colour")
+	}
+}
+
+// 跟踪每行一条JSON记录，序号从1连续递增，第一步扩展起点，最后一步扩展终点
+// 关闭跟踪后不再写入
+func TestTrace(t *testing.T) {
+	s, _ := renderSearch(t)
+	var buf bytes.Buffer
+	s.Trace(&buf)
+	path, err := s.FindPath(&Node{X: 0, Y: 2}, &Node{X: 4, Y: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var steps []TraceStep
+	scanner := bufio.NewScanner(&buf)
+	for scanner.Scan() {
+		var step TraceStep
+		if err := json.Unmarshal(scanner.Bytes(), &step); err != nil {
+			t.Fatalf("line %d: %v", len(steps)+1, err)
+		}
+		steps = append(steps, step)
+	}
+	if len(steps) == 0 {
+		t.Fatal("no trace written")
+	}
+	for i, step := range steps {
+		if step.Step != i+1 {
+			t.Fatalf("line %d has step %d", i+1, step.Step)
+		}
+	}
+	first, last := steps[0].TraceNode, steps[len(steps)-1].TraceNode
+	end := path.Points[len(path.Points)-1]
+	if first.X != 0 || first.Y != 2 || first.G != 0 || len(steps[0].Updated) == 0 {
+		t.Errorf("first step %+v, want the start with its neighbours", steps[0])
+	}
+	if last.X != end.X || last.Y != end.Y || last.G != path.Cost {
+		t.Errorf("last step %+v, want the end with cost %d", last, path.Cost)
+	}
+
+	s.Trace(nil)
+	if _, err := s.FindPath(&Node{X: 0, Y: 2}, &Node{X: 4, Y: 3}); err != nil {
+		t.Fatal(err)
+	}
+	if buf.Len() != 0 {
+		t.Errorf("trace written after it was turned off")
+	}
+}
@@ -0,0 +1,100 @@
+package astar
+
+import "encoding/json"
+
+// 寻路器
+// 保存单次搜索的全部状态（节点成本、开放、关闭列表），
+// 同一时间只能被一个goroutine使用，通过AStar.Acquire从池中获取
+type Searcher struct {
+	astar *AStar
+	// 搜索节点，按x*Rows+y存放
+	nodes []Node
+	start *Node
+	end   *Node
+	// 开放、关闭列表
+	openList  nodeHeap
+	closeList []*Node
+	// 当前搜索批次，每次FindPath递增
+	search int
+	// 搜索范围，为空时不限
+	limit *rect
+	// 单位边长，单位占据以节点为左上角的size*size个格子，0和1表示单格
+	size int
+	// 为true时忽略MaxExpanded和Weight，总是搜索最短路径，用于需要缓存的结果
+	exact bool
+	// 跟踪输出，为空时不跟踪
+	trace *json.Encoder
+}
+
+// 矩形范围，包含边界
+type rect struct {
+	x0, y0 int
+	x1, y1 int
+}
+
+func (r rect) contains(x, y int) bool {
+	return x >= r.x0 && x <= r.x1 && y >= r.y0 && y <= r.y1
+}
+
+func (r *AStar) NewSearcher() *Searcher {
+	return &Searcher{
+		astar: r,
+		nodes: make([]Node, r.grid.Rows*r.grid.Cols),
+	}
+}
+
+// 从池中取出一个寻路器，用完后需要Release
+// Init更换地图后，池中寻路器的节点数与新地图不符时重新分配
+func (r *AStar) Acquire() *Searcher {
+	if v := r.pool.Get(); v != nil {
+		s := v.(*Searcher)
+		if size := r.grid.Rows * r.grid.Cols; len(s.nodes) != size {
+			s.nodes = make([]Node, size)
+		}
+		return s
+	}
+	return r.NewSearcher()
+}
+
+// 归还寻路器，归还后不能再访问它返回的节点
+// 跟踪输出、搜索范围、单位大小等只对本次使用有效，归还时清除
+func (r *AStar) Release(s *Searcher) {
+	s.trace = nil
+	s.limit = nil
+	s.size = 0
+	s.exact = false
+	r.pool.Put(s)
+}
+
+// 开始新一轮搜索
+// 只递增批次并清空列表，节点状态在首次访问时惰性重置
+func (r *Searcher) reset() {
+	r.search++
+	r.openList = r.openList[:0]
+	r.closeList = r.closeList[:0]
+}
+
+// 开始一轮搜索，把起点放进开放列表
+func (r *Searcher) begin(start, end *Node) {
+	r.reset()
+	r.start = r.getNode(start.X, start.Y)
+	r.end = r.getNode(end.X, end.Y)
+	r.start.H = r.heuristic(r.start)
+	r.start.F = r.start.H
+	r.openListAppend(r.start)
+}
+
+// 获取节点，上一轮搜索遗留的状态会被清除
+func (r *Searcher) getNode(x, y int) *Node {
+	node := &r.nodes[x*r.astar.grid.Rows+y]
+	if node.search != r.search {
+		*node = Node{
+			X:      x,
+			Y:      y,
+			Type:   r.astar.grid.TypeAt(x, y),
+			index:  -1,
+			search: r.search,
+		}
+	}
+	return node
+}
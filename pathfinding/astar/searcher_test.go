@@ -0,0 +1,55 @@
+package astar
+
+import (
+	"bytes"
+	"testing"
+)
+
+// 归还的寻路器不再写入之前设置的跟踪输出
+func TestReleaseClearsTrace(t *testing.T) {
+	r, err := NewAStar([][]int{{0, 0, 0}, {0, 0, 0}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	s := r.Acquire()
+	s.Trace(&buf)
+	if _, err := s.FindPath(&Node{X: 0, Y: 0}, &Node{X: 2, Y: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if buf.Len() == 0 {
+		t.Fatal("trace is empty")
+	}
+	r.Release(s)
+	n := buf.Len()
+	s = r.Acquire()
+	defer r.Release(s)
+	if _, err := s.FindPath(&Node{X: 2, Y: 1}, &Node{X: 0, Y: 0}); err != nil {
+		t.Fatal(err)
+	}
+	if buf.Len() != n {
+		t.Fatalf("released searcher wrote %d more trace bytes", buf.Len()-n)
+	}
+}
+
+// Init换成更大的地图后，池中的寻路器仍然可用
+func TestAcquireAfterInit(t *testing.T) {
+	r, err := NewAStar([][]int{{0, 0}, {0, 0}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.FindPath(&Node{X: 0, Y: 0}, &Node{X: 1, Y: 1}); err != nil {
+		t.Fatal(err)
+	}
+	r.Rows, r.Cols = 4, 4
+	if err := r.Init([][]int{{0, 0, 0, 0}, {0, 0, 0, 0}, {0, 0, 0, 0}, {0, 0, 0, 0}}); err != nil {
+		t.Fatal(err)
+	}
+	path, err := r.FindPath(&Node{X: 0, Y: 0}, &Node{X: 3, Y: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if path.Cost != 3*COST_DIAGONAL {
+		t.Fatalf("cost %d, want %d", path.Cost, 3*COST_DIAGONAL)
+	}
+}
@@ -0,0 +1,22 @@
+package astar
+
+import "pathfinding/smooth"
+
+// 路径平滑的选项，见smooth包
+type SmoothOptions = smooth.Options
+
+const (
+	LINE_OF_SIGHT_BRESENHAM  = smooth.LINE_OF_SIGHT_BRESENHAM
+	LINE_OF_SIGHT_SUPERCOVER = smooth.LINE_OF_SIGHT_SUPERCOVER
+)
+
+// 按移动方式平滑路径，返回新的路径，原路径不变
+// 路径中相邻的点应是相邻的格子，即FindPath等方法的结果
+// 任意角度路径的成本按欧氏长度计算，与原路径的成本单位不同，见smooth包
+func (r *AStar) Smooth(path *Path, opts SmoothOptions) *Path {
+	points, cost := smooth.Path(r.grid, r.Movement, path.Points, opts)
+	smoothed := *path
+	smoothed.Points = points
+	smoothed.Cost = cost
+	return &smoothed
+}
@@ -0,0 +1,92 @@
+package astar
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"pathfinding/smooth"
+	"testing"
+)
+
+// 100张随机地图上，平滑后的路径保留起止点和替换标记，格子路径每一步都合法且成本不高于原路径，
+// 任意角度路径的长线段都有视线，成本不高于按同样单位计算的原路径
+func TestSmoothRandomMaps(t *testing.T) {
+	rnd := rand.New(rand.NewSource(16))
+	movements := []int{MOVEMENT_EIGHT, MOVEMENT_FOUR, MOVEMENT_EIGHT_NO_CORNER_CUT, MOVEMENT_EIGHT_NO_OBSTACLE}
+	snapped, redirected := 0, 0
+	for i := 0; i < 100; i++ {
+		r, err := NewAStar(randomMap(rnd, 20, 20, 0.25, true), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r.Movement = movements[i%len(movements)]
+		r.SnapRadius = 2
+		r.Redirect = true
+		// 起止点可能被挡住或不可达，路径的首尾是替换后的格子
+		path, err := r.FindPath(&Node{X: rnd.Intn(r.Cols), Y: rnd.Intn(r.Rows)}, &Node{X: rnd.Intn(r.Cols), Y: rnd.Intn(r.Rows)})
+		if err != nil {
+			continue
+		}
+		if path.SnappedStart || path.SnappedEnd {
+			snapped++
+		}
+		if path.Redirected {
+			redirected++
+		}
+		start, end := path.Points[0], path.Points[len(path.Points)-1]
+		for _, opts := range []SmoothOptions{
+			{LineOfSight: LINE_OF_SIGHT_BRESENHAM},
+			{LineOfSight: LINE_OF_SIGHT_SUPERCOVER},
+			{LineOfSight: LINE_OF_SIGHT_BRESENHAM, AnyAngle: true},
+			{LineOfSight: LINE_OF_SIGHT_SUPERCOVER, AnyAngle: true},
+		} {
+			t.Run(fmt.Sprintf("map%d/%+v", i, opts), func(t *testing.T) {
+				smoothed := r.Smooth(path, opts)
+				points := smoothed.Points
+				if points[0] != start || points[len(points)-1] != end {
+					t.Fatalf("smoothed path %v does not run from %v to %v", points, start, end)
+				}
+				if smoothed.Redirected != path.Redirected || smoothed.SnappedStart != path.SnappedStart || smoothed.SnappedEnd != path.SnappedEnd {
+					t.Fatalf("smoothed %+v lost the flags of %+v", smoothed, path)
+				}
+				if opts.AnyAngle {
+					checkAnyAngle(t, r, path, smoothed, opts)
+					return
+				}
+				checkPath(t, r, smoothed, start, end)
+				if smoothed.Cost > path.Cost {
+					t.Fatalf("smoothed cost %d above original %d", smoothed.Cost, path.Cost)
+				}
+			})
+		}
+	}
+	if snapped == 0 || redirected == 0 {
+		t.Fatalf("%d snapped and %d redirected paths, want both", snapped, redirected)
+	}
+}
+
+// 任意角度路径中不相邻的两点之间有视线，成本等于各段成本之和，不高于按欧氏长度计算的原路径
+func checkAnyAngle(t *testing.T, r *AStar, path, smoothed *Path, opts SmoothOptions) {
+	t.Helper()
+	r.grid.RLock()
+	defer r.grid.RUnlock()
+	original, cost := 0.0, 0.0
+	for i := 1; i < len(path.Points); i++ {
+		original += smooth.LineCost(r.grid, path.Points[i-1:i+1], opts)
+	}
+	for i := 1; i < len(smoothed.Points); i++ {
+		a, b := smoothed.Points[i-1], smoothed.Points[i]
+		// 相邻格子之间是原路径的一步，不需要视线
+		line := []Point{a, b}
+		if abs(b.X-a.X) > 1 || abs(b.Y-a.Y) > 1 {
+			var ok bool
+			if line, ok = smooth.Sight(r.grid, r.Movement, a, b, opts); !ok {
+				t.Fatalf("no sight %v -> %v in %v", a, b, smoothed.Points)
+			}
+		}
+		cost += smooth.LineCost(r.grid, line, opts)
+	}
+	if int(math.Round(cost)) != smoothed.Cost || smoothed.Cost > int(math.Round(original)) {
+		t.Fatalf("any-angle cost %d, segments add up to %.2f, original %.2f", smoothed.Cost, cost, original)
+	}
+}
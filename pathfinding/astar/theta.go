@@ -0,0 +1,135 @@
+package astar
+
+import (
+	"container/heap"
+	"math"
+	"pathfinding/smooth"
+)
+
+/*
+任意角度寻路（Theta*）
+与A*相同地逐格扩展，但相邻节点的父节点可以是当前节点的父节点，只要两者之间有视线，
+路径由任意角度的直线段组成，接近欧氏最短路径
+Lazy Theta*在生成相邻节点时假设视线成立，扩展时才检查，
+视线不成立再从已关闭的相邻节点中选择父节点，视线检查次数少很多
+视线按Bresenham直线检查，每一步都符合移动方式，成本按直线的欧氏长度计算，
+启发函数固定为欧几里得
+*/
+
+// Theta*寻路，路径只包含拐点，可被多个goroutine同时调用
+func (r *AStar) FindPathTheta(start, end *Node) (*Path, error) {
+	return r.findPathAnyAngle(start, end, false)
+}
+
+// Lazy Theta*寻路，路径只包含拐点，可被多个goroutine同时调用
+func (r *AStar) FindPathLazyTheta(start, end *Node) (*Path, error) {
+	return r.findPathAnyAngle(start, end, true)
+}
+
+// 起止点的处理（SnapRadius、Redirect、连通区域）与FindPath相同
+func (r *AStar) findPathAnyAngle(start, end *Node, lazy bool) (*Path, error) {
+	r.grid.RLock()
+	defer r.grid.RUnlock()
+	s := r.Acquire()
+	defer r.Release(s)
+	e, err := s.resolve(start, end)
+	if err != nil {
+		return nil, err
+	}
+	node := s.findAnyAngle(e.start, e.end, lazy)
+	if node == nil {
+		return nil, ErrNoPath
+	}
+	path := newPath(node, len(s.closeList))
+	e.mark(path)
+	return path, nil
+}
+
+// 任意角度搜索，返回终点节点，沿Parent回溯可得拐点
+func (r *Searcher) findAnyAngle(start, end *Node, lazy bool) *Node {
+	r.begin(start, end)
+	r.start.H = r.euclidean(r.start)
+	r.start.F = r.start.H
+	for len(r.openList) > 0 {
+		node := r.openListPop()
+		// Lazy Theta*：检查生成时假设的视线，按实际成本修正G，
+		// 视线不成立或实际成本高于估计时，再比较经过已关闭的相邻节点的路径
+		if lazy && node.Parent != nil {
+			estimate := node.G
+			cost, ok := r.sightCost(node.Parent, node)
+			if ok {
+				node.G = node.Parent.G + cost
+			}
+			if !ok || node.G > estimate {
+				for _, neighbor := range r.neighbors(node) {
+					if !neighbor.isClosed() {
+						continue
+					}
+					cost, sight := r.sightCost(neighbor, node)
+					if g := neighbor.G + cost; sight && (!ok || g < node.G) {
+						node.G, node.Parent, ok = g, neighbor, true
+					}
+				}
+			}
+			// 没有可以连过来的父节点，放回未访问状态，之后仍可以从其他节点生成
+			if !ok {
+				node.State = NODE_STATE_NORMAL
+				continue
+			}
+		}
+		if r.isEnd(node) {
+			return node
+		}
+		for _, neighbor := range r.neighbors(node) {
+			if neighbor.isClosed() {
+				continue
+			}
+			// 尝试从父节点直接连到相邻节点，地形成本不同时直线不一定更便宜，取两者中成本低的
+			parent := node
+			cost, _ := r.sightCost(node, neighbor)
+			if node.Parent != nil {
+				if lazy {
+					if c := r.estimateCost(node.Parent, neighbor); node.Parent.G+c <= node.G+cost {
+						parent, cost = node.Parent, c
+					}
+				} else if c, ok := r.sightCost(node.Parent, neighbor); ok && node.Parent.G+c <= node.G+cost {
+					parent, cost = node.Parent, c
+				}
+			}
+			g := parent.G + cost
+			if !neighbor.isOpened() || g < neighbor.G {
+				neighbor.G = g
+				neighbor.H = r.euclidean(neighbor)
+				neighbor.F = neighbor.G + r.astar.inflate(neighbor.H)
+				neighbor.Parent = parent
+				if !neighbor.isOpened() {
+					r.openListAppend(neighbor)
+				} else {
+					heap.Fix(&r.openList, neighbor.index)
+				}
+			}
+		}
+		r.closeListAppend(node)
+	}
+	return nil
+}
+
+// 从a直线移动到b的成本，没有视线时返回false
+func (r *Searcher) sightCost(a, b *Node) (int, bool) {
+	line, ok := smooth.Sight(r.astar.grid, r.astar.Movement, Point{X: a.X, Y: a.Y}, Point{X: b.X, Y: b.Y}, SmoothOptions{})
+	if !ok {
+		return 0, false
+	}
+	return int(math.Round(smooth.LineCost(r.astar.grid, line, SmoothOptions{AnyAngle: true}))), true
+}
+
+// 不检查视线的直线成本，倍率取终点格子的倍率，不高于实际成本
+func (r *Searcher) estimateCost(a, b *Node) int {
+	line := []Point{{X: a.X, Y: a.Y}, {X: b.X, Y: b.Y}}
+	return int(math.Round(smooth.LineCost(r.astar.grid, line, SmoothOptions{AnyAngle: true})))
+}
+
+// 欧几里得启发值
+func (r *Searcher) euclidean(node *Node) int {
+	return r.astar.grid.ScaleHeuristic(Euclidean(node, r.end))
+}
@@ -0,0 +1,125 @@
+package astar
+
+import (
+	"math"
+	"math/rand"
+	"pathfinding/smooth"
+	"testing"
+)
+
+// 没有障碍时任意角度路径只有起止两点，成本为直线长度
+func TestThetaOpenMap(t *testing.T) {
+	mapData := make([][]int, 6)
+	for y := range mapData {
+		mapData[y] = make([]int, 10)
+	}
+	r, err := NewAStar(mapData, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	start, end := &Node{X: 0, Y: 0}, &Node{X: 9, Y: 4}
+	want := int(math.Round(math.Hypot(9, 4) * COST_STRAIGHT))
+	for name, find := range map[string]func(start, end *Node) (*Path, error){
+		"theta":      r.FindPathTheta,
+		"lazy theta": r.FindPathLazyTheta,
+	} {
+		path, err := find(start, end)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if len(path.Points) != 2 || path.Cost != want {
+			t.Errorf("%s: points %v cost %d, want a straight line costing %d", name, path.Points, path.Cost, want)
+		}
+	}
+}
+
+// 绕过墙的任意角度路径，每一段都有视线
+func TestThetaAroundWall(t *testing.T) {
+	mapData := make([][]int, 6)
+	for y := range mapData {
+		mapData[y] = make([]int, 10)
+		if y < 4 {
+			mapData[y][4] = NODE_TYPE_OBSTACLE
+		}
+	}
+	r, err := NewAStar(mapData, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	start, end := Point{X: 0, Y: 0}, Point{X: 9, Y: 0}
+	for _, lazy := range []bool{false, true} {
+		path, err := r.findPathAnyAngle(&Node{X: start.X, Y: start.Y}, &Node{X: end.X, Y: end.Y}, lazy)
+		if err != nil {
+			t.Fatalf("lazy %v: %v", lazy, err)
+		}
+		points := path.Points
+		if len(points) < 3 || points[0] != start || points[len(points)-1] != end {
+			t.Fatalf("lazy %v: path %v does not turn around the wall from %v to %v", lazy, points, start, end)
+		}
+		r.grid.RLock()
+		for i := 1; i < len(points); i++ {
+			if _, ok := smooth.Sight(r.grid, r.Movement, points[i-1], points[i], SmoothOptions{}); !ok {
+				t.Errorf("lazy %v: no line of sight %v -> %v in %v", lazy, points[i-1], points[i], points)
+			}
+		}
+		r.grid.RUnlock()
+	}
+}
+
+// 任意角度路径的每一段都有视线，成本等于各段直线成本之和，不高于A*路径的实际长度
+func TestThetaNotLongerThanAStar(t *testing.T) {
+	rnd := rand.New(rand.NewSource(8))
+	for _, terrain := range []bool{false, true} {
+		r, err := NewAStar(randomMap(rnd, 30, 30, 0.2, terrain), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, movement := range []int{MOVEMENT_EIGHT, MOVEMENT_EIGHT_NO_CORNER_CUT, MOVEMENT_EIGHT_NO_OBSTACLE} {
+			r.Movement = movement
+			for i := 0; i < 100; i++ {
+				start, end := randomWalkable(rnd, r), randomWalkable(rnd, r)
+				want, err := r.FindPath(&Node{X: start.X, Y: start.Y}, &Node{X: end.X, Y: end.Y})
+				for _, lazy := range []bool{false, true} {
+					got, thetaErr := r.findPathAnyAngle(&Node{X: start.X, Y: start.Y}, &Node{X: end.X, Y: end.Y}, lazy)
+					if (err == nil) != (thetaErr == nil) {
+						t.Fatalf("terrain %v movement %d lazy %v %v -> %v: astar %v, theta %v", terrain, movement, lazy, start, end, err, thetaErr)
+					}
+					if err != nil {
+						continue
+					}
+					cost := checkSegments(t, r, got.Points, start, end)
+					// A*的对角成本按14计算，比实际长度略短，任意角度路径每段成本取整
+					limit := float64(want.Cost)*math.Sqrt2*10/COST_DIAGONAL + float64(len(got.Points))
+					if cost != got.Cost || float64(got.Cost) > limit {
+						t.Fatalf("terrain %v movement %d lazy %v %v -> %v: cost %d, segments %d, astar %d", terrain, movement, lazy, start, end, got.Cost, cost, want.Cost)
+					}
+				}
+			}
+		}
+	}
+}
+
+// 检查每一段都有视线，返回各段成本之和
+func checkSegments(t *testing.T, r *AStar, points []Point, start, end Point) int {
+	t.Helper()
+	if len(points) == 0 || points[0] != start || points[len(points)-1] != end {
+		t.Fatalf("path %v does not run from %v to %v", points, start, end)
+	}
+	g := r.Grid()
+	g.RLock()
+	defer g.RUnlock()
+	cost := 0
+	for i := 1; i < len(points); i++ {
+		line, ok := smooth.Sight(g, r.Movement, points[i-1], points[i], SmoothOptions{})
+		if !ok {
+			t.Fatalf("no line of sight %v -> %v in %v", points[i-1], points[i], points)
+		}
+		cost += int(smooth.LineCost(g, line, SmoothOptions{AnyAngle: true}) + 0.5)
+	}
+	return cost
+}
+
+func TestFindPathThetaResolve(t *testing.T) {
+	checkResolve(t, (*AStar).FindPathTheta)
+	checkResolve(t, (*AStar).FindPathLazyTheta)
+}
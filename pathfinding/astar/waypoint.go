@@ -0,0 +1,52 @@
+package astar
+
+import "math"
+
+/*
+路点图
+路点是平面上的任意位置，相连的路点之间可以直线移动，成本为两点间的距离
+导航网格也可以用路点图表示：每个多边形取一个路点（如中心），相邻多边形的路点相连
+*/
+
+// 路点的位置
+type Waypoint struct {
+	X float64
+	Y float64
+}
+
+type WaypointGraph struct {
+	Points []Waypoint
+	// 每个路点相连的路点编号
+	edges [][]int
+}
+
+// 添加路点，返回编号
+func (g *WaypointGraph) AddPoint(p Waypoint) int {
+	g.Points = append(g.Points, p)
+	g.edges = append(g.edges, nil)
+	return len(g.Points) - 1
+}
+
+// 双向连接两个路点
+func (g *WaypointGraph) Connect(a, b int) {
+	g.edges[a] = append(g.edges[a], b)
+	g.edges[b] = append(g.edges[b], a)
+}
+
+func (g *WaypointGraph) Neighbors(id int) []int {
+	return g.edges[id]
+}
+
+// 距离向上取整，保证路径上的成本之和不低于启发值
+func (g *WaypointGraph) Cost(id, next int) int {
+	return int(math.Ceil(g.distance(id, next) * COST_STRAIGHT))
+}
+
+func (g *WaypointGraph) Heuristic(id, end int) int {
+	return int(g.distance(id, end) * COST_STRAIGHT)
+}
+
+func (g *WaypointGraph) distance(a, b int) float64 {
+	pa, pb := g.Points[a], g.Points[b]
+	return math.Hypot(pa.X-pb.X, pa.Y-pb.Y)
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"path/filepath"
+	"pathfinding/astar"
+	"pathfinding/grid"
+	"pathfinding/jps"
+	"runtime"
+	"text/tabwriter"
+	"time"
+)
+
+// 场景路径长度允许的相对误差
+// 对角成本按14计算（√2×10≈14.14），最短路径的实际长度与最优值可能有微小偏差
+const scenarioTolerance = 0.01
+
+// 场景测试结果，同一个查询分别由A*和跳点搜索执行
+type ScenarioResult struct {
+	grid.Scenario
+	AStar QueryResult `json:"astar"`
+	Jps   QueryResult `json:"jps"`
+}
+
+// 单个算法的查询结果
+type QueryResult struct {
+	// 路径长度，直线为1，对角为√2
+	Length   float64       `json:"length"`
+	Cost     int           `json:"cost"`
+	Expanded int           `json:"expanded"`
+	Duration time.Duration `json:"duration_ns"`
+	// 单次查询分配的内存
+	AllocBytes uint64 `json:"alloc_bytes"`
+	Allocs     uint64 `json:"allocs"`
+	// 路径长度是否与最优值一致
+	Ok    bool   `json:"ok"`
+	Error string `json:"error,omitempty"`
+}
+
+// 一次寻路，返回路径、成本和扩展节点数
+type findFunc func(start, end grid.Point) (points []grid.Point, cost, expanded int, err error)
+
+// 同一张地图上的两种寻路
+type finders struct {
+	astar findFunc
+	jps   findFunc
+}
+
+// 依次执行场景中的寻路，每个场景使用load返回的地图，记录耗时、内存和扩展节点数
+func RunScenarios(load func(s grid.Scenario) (*finders, error), scenarios []grid.Scenario) ([]ScenarioResult, error) {
+	results := make([]ScenarioResult, 0, len(scenarios))
+	for _, s := range scenarios {
+		f, err := load(s)
+		if err != nil {
+			return nil, err
+		}
+		result := ScenarioResult{Scenario: s}
+		result.AStar = runQuery(f.astar, s)
+		result.Jps = runQuery(f.jps, s)
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+func runQuery(find findFunc, s grid.Scenario) QueryResult {
+	var result QueryResult
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	begin := time.Now()
+	points, cost, expanded, err := find(s.Start, s.End)
+	result.Duration = time.Since(begin)
+	runtime.ReadMemStats(&after)
+	result.AllocBytes = after.TotalAlloc - before.TotalAlloc
+	result.Allocs = after.Mallocs - before.Mallocs
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	result.Length = octileLength(points)
+	result.Cost = cost
+	result.Expanded = expanded
+	result.Ok = math.Abs(result.Length-s.Optimal) <= s.Optimal*scenarioTolerance+1e-6
+	return result
+}
+
+// 路径按八方向计算的长度，相邻两点之间可以是直线或对角线段
+func octileLength(points []grid.Point) float64 {
+	length := 0.0
+	for i := 1; i < len(points); i++ {
+		x := math.Abs(float64(points[i].X - points[i-1].X))
+		y := math.Abs(float64(points[i].Y - points[i-1].Y))
+		length += min(x, y)*math.Sqrt2 + max(x, y) - min(x, y)
+	}
+	return length
+}
+
+func WriteResults(w io.Writer, results []ScenarioResult, asJSON bool) error {
+	if asJSON {
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(results)
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "\t\t\t\tastar\t\t\t\t\tjps\t\t\t\t\t")
+	fmt.Fprintln(tw, "bucket\tstart\tend\toptimal\tlength\texpanded\ttime\talloc\tok\tlength\texpanded\ttime\talloc\tok\t")
+	var totals [2]struct {
+		duration time.Duration
+		expanded int
+		failed   int
+	}
+	for _, r := range results {
+		fmt.Fprintf(tw, "%d\t%d,%d\t%d,%d\t%.4f\t", r.Bucket, r.Start.X, r.Start.Y, r.End.X, r.End.Y, r.Optimal)
+		for i, q := range []QueryResult{r.AStar, r.Jps} {
+			fmt.Fprintf(tw, "%.4f\t%d\t%s\t%dB\t%v\t", q.Length, q.Expanded, q.Duration, q.AllocBytes, q.Ok)
+			totals[i].duration += q.Duration
+			totals[i].expanded += q.Expanded
+			if !q.Ok {
+				totals[i].failed++
+			}
+		}
+		fmt.Fprintln(tw)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	for i, name := range []string{"astar", "jps"} {
+		t := totals[i]
+		_, err := fmt.Fprintf(w, "%s queries: %d failed: %d expanded: %d time: %s\n", name, len(results), t.failed, t.expanded, t.duration)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 读取场景文件，每个查询分别用A*和跳点搜索执行
+// mapFile为空时每个场景使用自己记录的地图（相对于场景文件所在目录），同一张地图只加载一次
+func runScenarios(w io.Writer, mapFile, scenFile string, asJSON bool) error {
+	scenarios, err := grid.LoadScenarios(scenFile)
+	if err != nil {
+		return err
+	}
+	if len(scenarios) == 0 {
+		return fmt.Errorf("%s has no scenarios", scenFile)
+	}
+	loaded := make(map[string]*finders)
+	load := func(s grid.Scenario) (*finders, error) {
+		name := mapFile
+		if name == "" {
+			name = filepath.Join(filepath.Dir(scenFile), s.Map)
+		}
+		if f, ok := loaded[name]; ok {
+			return f, nil
+		}
+		mapData, err := grid.LoadMap(name)
+		if err != nil {
+			return nil, err
+		}
+		f := &finders{}
+		if f.astar, err = astarFinder(mapData); err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		if f.jps, err = jpsFinder(mapData); err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		loaded[name] = f
+		return f, nil
+	}
+	results, err := RunScenarios(load, scenarios)
+	if err != nil {
+		return err
+	}
+	return WriteResults(w, results, asJSON)
+}
+
+func astarFinder(mapData [][]int) (findFunc, error) {
+	r, err := astar.NewAStar(mapData, nil)
+	if err != nil {
+		return nil, err
+	}
+	// Moving AI场景不允许斜向穿过障碍的拐角
+	r.Movement = astar.MOVEMENT_EIGHT_NO_OBSTACLE
+	return func(start, end grid.Point) ([]grid.Point, int, int, error) {
+		path, err := r.FindPath(&astar.Node{X: start.X, Y: start.Y}, &astar.Node{X: end.X, Y: end.Y})
+		if err != nil {
+			return nil, 0, 0, err
+		}
+		return path.Points, path.Cost, path.Expanded, nil
+	}, nil
+}
+
+func jpsFinder(mapData [][]int) (findFunc, error) {
+	r, err := jps.NewJps(mapData)
+	if err != nil {
+		return nil, err
+	}
+	// Moving AI场景不允许斜向穿过障碍的拐角
+	r.NoCornerCut = true
+	return func(start, end grid.Point) ([]grid.Point, int, int, error) {
+		path, err := r.FindPath(&jps.Node{X: start.X, Y: start.Y}, &jps.Node{X: end.X, Y: end.Y})
+		if err != nil {
+			return nil, 0, 0, err
+		}
+		return path.Points, path.Cost, path.Expanded, nil
+	}, nil
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"os"
+	"path/filepath"
+	"pathfinding/grid"
+	"testing"
+)
+
+// 每个场景读取自己记录的地图（相对于场景文件），A*和跳点搜索分别执行每个查询
+// 路径长度与最优值不符的查询标记为失败，跳点搜索与A*一样不允许斜穿拐角
+func TestRunScenarios(t *testing.T) {
+	dir := t.TempDir()
+	maps := map[string][][]int{
+		"a.map": {
+			{0, 0, 0, 0},
+			{0, 1, 1, 0},
+			{0, 0, 0, 0},
+		},
+		"b.map": {
+			{0, 0, 0, 0},
+			{0, 0, 0, 0},
+			{0, 0, 0, 0},
+		},
+		"c.map": {
+			{0, 1, 0},
+			{0, 0, 0},
+		},
+	}
+	for name, mapData := range maps {
+		var buf bytes.Buffer
+		if err := grid.WriteMovingAI(&buf, mapData); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, name), buf.Bytes(), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	scenarios := []grid.Scenario{
+		{Map: "a.map", Width: 4, Height: 3, Start: grid.Point{X: 0, Y: 2}, End: grid.Point{X: 3, Y: 2}, Optimal: 3},
+		{Map: "a.map", Width: 4, Height: 3, Start: grid.Point{X: 0, Y: 0}, End: grid.Point{X: 3, Y: 0}, Optimal: 2},
+		{Map: "a.map", Width: 4, Height: 3, Start: grid.Point{X: 1, Y: 1}, End: grid.Point{X: 3, Y: 1}, Optimal: 2},
+		{Map: "b.map", Width: 4, Height: 3, Start: grid.Point{X: 1, Y: 1}, End: grid.Point{X: 3, Y: 1}, Optimal: 2},
+		{Map: "c.map", Width: 3, Height: 2, Start: grid.Point{X: 0, Y: 0}, End: grid.Point{X: 1, Y: 1}, Optimal: 2},
+		{Map: "c.map", Width: 3, Height: 2, Start: grid.Point{X: 0, Y: 0}, End: grid.Point{X: 1, Y: 1}, Optimal: math.Sqrt2},
+	}
+	var buf bytes.Buffer
+	if err := grid.WriteScenarios(&buf, scenarios); err != nil {
+		t.Fatal(err)
+	}
+	scenFile := filepath.Join(dir, "maps.scen")
+	if err := os.WriteFile(scenFile, buf.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	buf.Reset()
+	if err := runScenarios(&buf, "", scenFile, true); err != nil {
+		t.Fatal(err)
+	}
+	var results []ScenarioResult
+	if err := json.Unmarshal(buf.Bytes(), &results); err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != len(scenarios) {
+		t.Fatalf("%d results, want %d", len(results), len(scenarios))
+	}
+	// 第二个查询的最优值与实际长度3不符，第三个查询的起点在a.map中是障碍，在b.map中可行
+	// 最后一个查询的最优值是斜穿拐角的长度，不斜穿时长度为2
+	ok := []bool{true, false, false, true, true, false}
+	for i, result := range results {
+		for name, q := range map[string]QueryResult{"astar": result.AStar, "jps": result.Jps} {
+			if q.Ok != ok[i] || (i == 2) != (q.Error != "") {
+				t.Errorf("query %d %s: %+v", i, name, q)
+			}
+		}
+	}
+}
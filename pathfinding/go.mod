@@ -0,0 +1,3 @@
+module pathfinding
+
+go 1.21
@@ -0,0 +1,551 @@
+package grid
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+/*
+地图从左上角开始，水平x 垂直y
+  y
+x 0,0 1,0 2,0
+  0,1 1,1 2,1
+  0,2 1,2 2,2
+A*、跳点搜索和依赖地图的缓存（分层寻路、流场、连通区域等）共用同一个地图
+*/
+
+// 移动成本
+const (
+	COST_STRAIGHT = 10
+	COST_DIAGONAL = 14
+)
+
+// 移动方式
+const (
+	MOVEMENT_EIGHT               = iota // 8方向
+	MOVEMENT_FOUR                       // 4方向
+	MOVEMENT_EIGHT_NO_CORNER_CUT        // 8方向，两侧都是障碍时不能斜向穿过
+	MOVEMENT_EIGHT_NO_OBSTACLE          // 8方向，两侧都可行时才能斜向移动
+)
+
+// 成本倍率（百分比）
+const (
+	COST_RATE_NORMAL = 100
+	COST_RATE_ROAD   = 50
+	COST_RATE_SWAMP  = 300
+	COST_RATE_WATER  = 500
+)
+
+// 节点类型
+const (
+	NODE_TYPE_NORMAL = iota
+	NODE_TYPE_OBSTACLE
+	NODE_TYPE_ROAD  // 道路
+	NODE_TYPE_SWAMP // 沼泽
+	NODE_TYPE_WATER // 浅水
+)
+
+// 坐标
+type Point struct {
+	X int `json:"x"`
+	Y int `json:"y"`
+}
+
+var (
+	ErrOutOfBounds = errors.New("point is out of bounds")
+	ErrInvalidMap  = errors.New("invalid map data")
+)
+
+// 地图
+// 记录每个格子的类型和移动成本倍率，可以被多个搜索同时访问
+// 搜索期间持有读锁，修改格子时持有写锁，修改完成后通知订阅者
+// 不加锁的方法（TypeAt、IsWalkable、StepCost等）供搜索使用，调用方需要先RLock
+type Grid struct {
+	// 地图大小
+	Rows int // y
+	Cols int // x
+	// 节点类型，按[x][y]存放
+	types [][]int
+	// 进入节点的成本倍率（百分比），按[x][y]存放
+	rates [][]int
+	// 真实净空：以格子为左上角、全部可行的最大正方形边长，按[x][y]存放
+	clearance [][]int
+	// 可行节点中最小的成本倍率，用于缩放启发值
+	minRate int
+	// 版本，每次修改格子递增
+	version int
+	mu      sync.RWMutex
+	// 格子变化的订阅者
+	subscribers map[int]func(Change)
+	nextID      int
+}
+
+// 格子变化
+type Change struct {
+	X       int
+	Y       int
+	Type    int
+	Rate    int
+	OldType int
+	OldRate int
+	// 修改后地图的版本
+	Version int
+}
+
+// 是否改变了可行性
+func (c Change) WalkableChanged() bool {
+	return (c.Type == NODE_TYPE_OBSTACLE) != (c.OldType == NODE_TYPE_OBSTACLE)
+}
+
+// 地形默认的成本倍率
+var terrainRates = map[int]int{
+	NODE_TYPE_NORMAL: COST_RATE_NORMAL,
+	NODE_TYPE_ROAD:   COST_RATE_ROAD,
+	NODE_TYPE_SWAMP:  COST_RATE_SWAMP,
+	NODE_TYPE_WATER:  COST_RATE_WATER,
+}
+
+// costData与mapData同样按[y][x]排列，为空时按地形取默认倍率
+func NewGrid(rows, cols int, mapData, costData [][]int) (*Grid, error) {
+	if err := validateMap(rows, cols, mapData, costData); err != nil {
+		return nil, err
+	}
+	g := &Grid{
+		Rows: rows,
+		Cols: cols,
+	}
+	g.types = make([][]int, cols)
+	g.rates = make([][]int, cols)
+	for i := 0; i < cols; i++ {
+		g.types[i] = make([]int, rows)
+		g.rates[i] = make([]int, rows)
+	}
+	for i := 0; i < len(mapData); i++ {
+		for j := 0; j < len(mapData[i]); j++ {
+			g.types[j][i] = mapData[i][j]
+		}
+	}
+	for x := 0; x < cols; x++ {
+		for y := 0; y < rows; y++ {
+			rate, ok := terrainRates[g.types[x][y]]
+			if !ok {
+				rate = COST_RATE_NORMAL
+			}
+			if len(costData) > 0 {
+				rate = costData[y][x]
+			}
+			g.rates[x][y] = rate
+		}
+	}
+	g.minRate = g.findMinRate()
+	g.buildClearance()
+	return g, nil
+}
+
+// 检查地图数据是否是rows*cols的矩形，节点类型与成本倍率是否合法
+func validateMap(rows, cols int, mapData, costData [][]int) error {
+	if rows <= 0 || cols <= 0 {
+		return fmt.Errorf("%w: size %dx%d is empty", ErrInvalidMap, rows, cols)
+	}
+	if len(mapData) != rows {
+		return fmt.Errorf("%w: %d rows given, expected %d", ErrInvalidMap, len(mapData), rows)
+	}
+	for y, row := range mapData {
+		if len(row) != cols {
+			return fmt.Errorf("%w: row %d has %d cells, expected %d", ErrInvalidMap, y, len(row), cols)
+		}
+		for x, t := range row {
+			if t != NODE_TYPE_OBSTACLE && !isTerrain(t) {
+				return fmt.Errorf("%w: unknown node type %d at %d,%d", ErrInvalidMap, t, x, y)
+			}
+		}
+	}
+	if len(costData) == 0 {
+		return nil
+	}
+	if len(costData) != rows {
+		return fmt.Errorf("%w: %d cost rows given, expected %d", ErrInvalidMap, len(costData), rows)
+	}
+	for y, row := range costData {
+		if len(row) != cols {
+			return fmt.Errorf("%w: cost row %d has %d cells, expected %d", ErrInvalidMap, y, len(row), cols)
+		}
+		for x, rate := range row {
+			if rate <= 0 {
+				return fmt.Errorf("%w: cost rate %d at %d,%d must be positive", ErrInvalidMap, rate, x, y)
+			}
+		}
+	}
+	return nil
+}
+
+// 是否是可行的地形
+func isTerrain(t int) bool {
+	_, ok := terrainRates[t]
+	return ok
+}
+
+// 加读锁，搜索期间持有，期间地图不会变化
+func (g *Grid) RLock() {
+	g.mu.RLock()
+}
+
+func (g *Grid) RUnlock() {
+	g.mu.RUnlock()
+}
+
+// 节点类型
+func (g *Grid) Type(x, y int) int {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	return g.types[x][y]
+}
+
+// 进入节点的成本倍率
+func (g *Grid) Rate(x, y int) int {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	return g.rates[x][y]
+}
+
+// 以格子为左上角能容纳的最大单位边长，障碍为0
+func (g *Grid) Clearance(x, y int) int {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	return g.clearance[x][y]
+}
+
+// 节点类型，调用方持有读锁
+func (g *Grid) TypeAt(x, y int) int {
+	return g.types[x][y]
+}
+
+// 进入节点的成本倍率，调用方持有读锁
+func (g *Grid) RateAt(x, y int) int {
+	return g.rates[x][y]
+}
+
+// 可行节点中最小的成本倍率，调用方持有读锁
+func (g *Grid) MinRate() int {
+	return g.minRate
+}
+
+// 地图的版本，调用方持有读锁
+// 订阅者收到的变化版本与此相同时，说明已经收到了全部变化
+func (g *Grid) Version() int {
+	return g.version
+}
+
+// 设置格子是否可行
+// 可行的格子改为障碍时保留成本倍率，障碍改为可行时变为普通地形
+func (g *Grid) SetWalkable(x, y int, walkable bool) error {
+	return g.update(x, y, func(t, rate int) (int, int) {
+		if !walkable {
+			return NODE_TYPE_OBSTACLE, rate
+		}
+		if t == NODE_TYPE_OBSTACLE {
+			return NODE_TYPE_NORMAL, rate
+		}
+		return t, rate
+	})
+}
+
+// 设置格子的成本倍率（百分比）
+func (g *Grid) SetCost(x, y, rate int) error {
+	return g.update(x, y, func(t, _ int) (int, int) {
+		return t, rate
+	})
+}
+
+// 设置格子类型，成本倍率取该类型的默认值
+func (g *Grid) SetType(x, y, t int) error {
+	return g.update(x, y, func(_, rate int) (int, int) {
+		if r, ok := terrainRates[t]; ok {
+			rate = r
+		}
+		return t, rate
+	})
+}
+
+// 设置格子类型和成本倍率
+func (g *Grid) Set(x, y, t, rate int) error {
+	return g.update(x, y, func(_, _ int) (int, int) {
+		return t, rate
+	})
+}
+
+// 订阅格子变化，返回取消订阅的函数
+// 回调在修改完成、释放写锁后同步执行，可以读取地图
+func (g *Grid) Subscribe(f func(Change)) func() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.subscribers == nil {
+		g.subscribers = make(map[int]func(Change))
+	}
+	id := g.nextID
+	g.nextID++
+	g.subscribers[id] = f
+	return func() {
+		g.mu.Lock()
+		defer g.mu.Unlock()
+		delete(g.subscribers, id)
+	}
+}
+
+// 持有写锁修改格子，有变化时通知订阅者
+func (g *Grid) update(x, y int, f func(t, rate int) (int, int)) error {
+	g.mu.Lock()
+	if !g.InBounds(x, y) {
+		g.mu.Unlock()
+		return fmt.Errorf("%d,%d: %w", x, y, ErrOutOfBounds)
+	}
+	change := Change{
+		X:       x,
+		Y:       y,
+		OldType: g.types[x][y],
+		OldRate: g.rates[x][y],
+	}
+	change.Type, change.Rate = f(change.OldType, change.OldRate)
+	if change.Type == change.OldType && change.Rate == change.OldRate {
+		g.mu.Unlock()
+		return nil
+	}
+	if err := g.set(x, y, change.Type, change.Rate); err != nil {
+		g.mu.Unlock()
+		return err
+	}
+	change.Version = g.version
+	subscribers := make([]func(Change), 0, len(g.subscribers))
+	for _, f := range g.subscribers {
+		subscribers = append(subscribers, f)
+	}
+	g.mu.Unlock()
+	for _, f := range subscribers {
+		f(change)
+	}
+	return nil
+}
+
+// 复制地图，不包括订阅者
+func (g *Grid) Clone() *Grid {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	c := &Grid{
+		Rows:      g.Rows,
+		Cols:      g.Cols,
+		types:     make([][]int, g.Cols),
+		rates:     make([][]int, g.Cols),
+		clearance: make([][]int, g.Cols),
+		minRate:   g.minRate,
+		version:   g.version,
+	}
+	for x := 0; x < g.Cols; x++ {
+		c.types[x] = append([]int(nil), g.types[x]...)
+		c.rates[x] = append([]int(nil), g.rates[x]...)
+		c.clearance[x] = append([]int(nil), g.clearance[x]...)
+	}
+	return c
+}
+
+// 修改节点类型和成本倍率，调用方负责加锁
+func (g *Grid) set(x, y, t, rate int) error {
+	if !g.InBounds(x, y) {
+		return fmt.Errorf("%d,%d: %w", x, y, ErrOutOfBounds)
+	}
+	if t != NODE_TYPE_OBSTACLE && !isTerrain(t) {
+		return fmt.Errorf("%w: unknown node type %d at %d,%d", ErrInvalidMap, t, x, y)
+	}
+	if rate <= 0 {
+		return fmt.Errorf("%w: cost rate %d at %d,%d must be positive", ErrInvalidMap, rate, x, y)
+	}
+	old, walkable := g.rates[x][y], g.IsWalkable(x, y)
+	g.version++
+	g.types[x][y] = t
+	g.rates[x][y] = rate
+	if t != NODE_TYPE_OBSTACLE && rate < g.minRate {
+		g.minRate = rate
+	} else if old == g.minRate {
+		g.minRate = g.findMinRate()
+	}
+	if walkable != g.IsWalkable(x, y) {
+		g.updateClearance(x, y)
+	}
+	return nil
+}
+
+// 计算所有格子的净空，从右下角向左上角递推
+func (g *Grid) buildClearance() {
+	g.clearance = make([][]int, g.Cols)
+	for x := 0; x < g.Cols; x++ {
+		g.clearance[x] = make([]int, g.Rows)
+	}
+	for x := g.Cols - 1; x >= 0; x-- {
+		for y := g.Rows - 1; y >= 0; y-- {
+			g.clearance[x][y] = g.computeClearance(x, y)
+		}
+	}
+}
+
+// 格子可行性变化后更新净空
+// 格子的净空只依赖右、下、右下的格子，变化只会向左上传播，
+// 逐层重算，有变化的格子把左、上、左上的格子放进下一层，没有变化时停止
+func (g *Grid) updateClearance(x, y int) {
+	layer := []Point{{X: x, Y: y}}
+	for len(layer) > 0 {
+		seen := make(map[Point]bool)
+		next := make([]Point, 0)
+		for _, p := range layer {
+			c := g.computeClearance(p.X, p.Y)
+			if c == g.clearance[p.X][p.Y] {
+				continue
+			}
+			g.clearance[p.X][p.Y] = c
+			for _, q := range []Point{{X: p.X - 1, Y: p.Y}, {X: p.X, Y: p.Y - 1}, {X: p.X - 1, Y: p.Y - 1}} {
+				if g.InBounds(q.X, q.Y) && !seen[q] {
+					seen[q] = true
+					next = append(next, q)
+				}
+			}
+		}
+		layer = next
+	}
+}
+
+func (g *Grid) computeClearance(x, y int) int {
+	if !g.IsWalkable(x, y) {
+		return 0
+	}
+	return 1 + min(g.clearanceAt(x+1, y), min(g.clearanceAt(x, y+1), g.clearanceAt(x+1, y+1)))
+}
+
+// 越界按0处理
+func (g *Grid) clearanceAt(x, y int) int {
+	if !g.InBounds(x, y) {
+		return 0
+	}
+	return g.clearance[x][y]
+}
+
+func (g *Grid) findMinRate() int {
+	minRate := 0
+	for x := 0; x < g.Cols; x++ {
+		for y := 0; y < g.Rows; y++ {
+			if !g.IsWalkable(x, y) {
+				continue
+			}
+			if minRate == 0 || g.rates[x][y] < minRate {
+				minRate = g.rates[x][y]
+			}
+		}
+	}
+	if minRate == 0 {
+		minRate = COST_RATE_NORMAL
+	}
+	return minRate
+}
+
+// 从x,y移动到相邻节点nx,ny的成本，调用方持有读锁
+// 基础成本乘以nx,ny的倍率，向上取整，保证缩放后的启发值不会高估
+func (g *Grid) StepCost(x, y, nx, ny int) int {
+	cost := COST_DIAGONAL
+	if x == nx || y == ny {
+		cost = COST_STRAIGHT
+	}
+	return (cost*g.rates[nx][ny] + COST_RATE_NORMAL - 1) / COST_RATE_NORMAL
+}
+
+// 按移动方式判断能否从x,y向dx,dy方向移动一格，调用方持有读锁
+func (g *Grid) CanMove(movement, x, y, dx, dy int) bool {
+	return g.CanMoveSize(movement, 1, x, y, dx, dy)
+}
+
+// 占据size*size格子的单位能否从x,y向dx,dy方向移动一格，x,y为单位左上角
+func (g *Grid) CanMoveSize(movement, size, x, y, dx, dy int) bool {
+	if !g.Fits(x+dx, y+dy, size) {
+		return false
+	}
+	// 水平、垂直移动
+	if dx == 0 || dy == 0 {
+		return true
+	}
+	// 对角移动，检查两侧的节点
+	switch movement {
+	case MOVEMENT_FOUR:
+		return false
+	case MOVEMENT_EIGHT_NO_CORNER_CUT:
+		return g.Fits(x+dx, y, size) || g.Fits(x, y+dy, size)
+	case MOVEMENT_EIGHT_NO_OBSTACLE:
+		return g.Fits(x+dx, y, size) && g.Fits(x, y+dy, size)
+	}
+	return true
+}
+
+// 按最小倍率缩放启发值，调用方持有读锁
+// 启发函数按基础成本估算，乘以最小倍率后仍然不会高估实际成本
+func (g *Grid) ScaleHeuristic(h int) int {
+	return h * g.minRate / COST_RATE_NORMAL
+}
+
+// 坐标是否在地图内，调用方持有读锁
+func (g *Grid) InBounds(x, y int) bool {
+	// 最小越界
+	if x < 0 || y < 0 {
+		return false
+	}
+	// 最大越界
+	if x > g.Cols-1 || y > g.Rows-1 {
+		return false
+	}
+	return true
+}
+
+// radius范围内离p最近、能容纳size*size单位的格子，不包括p，调用方持有读锁
+// 按切比雪夫距离逐圈查找，直到圈的距离超过已找到的最近距离
+func (g *Grid) NearestFit(p Point, radius, size int) (Point, bool) {
+	var best Point
+	bestDist := -1
+	for r := 1; r <= radius; r++ {
+		if bestDist >= 0 && r*r > bestDist {
+			break
+		}
+		for x := p.X - r; x <= p.X+r; x++ {
+			for y := p.Y - r; y <= p.Y+r; y++ {
+				// 只检查圈上的格子
+				if abs(x-p.X) != r && abs(y-p.Y) != r {
+					continue
+				}
+				if !g.Fits(x, y, size) {
+					continue
+				}
+				dx, dy := x-p.X, y-p.Y
+				if dist := dx*dx + dy*dy; bestDist < 0 || dist < bestDist {
+					best, bestDist = Point{X: x, Y: y}, dist
+				}
+			}
+		}
+	}
+	return best, bestDist >= 0
+}
+
+// 占据size*size格子的单位能否以x,y为左上角站立，调用方持有读锁
+func (g *Grid) Fits(x, y, size int) bool {
+	if size <= 1 {
+		return g.IsWalkable(x, y)
+	}
+	return g.InBounds(x, y) && g.clearance[x][y] >= size
+}
+
+// 节点是否可行，越界时不可行，调用方持有读锁
+func (g *Grid) IsWalkable(x, y int) bool {
+	if !g.InBounds(x, y) {
+		return false
+	}
+	// 节点是否可行
+	return g.types[x][y] != NODE_TYPE_OBSTACLE
+}
+
+func abs(n int) int {
+	y := n >> 63
+	return (n ^ y) - y
+}
@@ -0,0 +1,50 @@
+package grid
+
+import (
+	"math/rand"
+	"testing"
+)
+
+// 逐格检查的净空：以x,y为左上角、全部可行的最大正方形边长
+func bruteClearance(g *Grid, x, y int) int {
+	size := 0
+	for {
+		for i := 0; i <= size; i++ {
+			if !g.IsWalkable(x+size, y+i) || !g.IsWalkable(x+i, y+size) {
+				return size
+			}
+		}
+		size++
+	}
+}
+
+// 初始化和每次修改格子后，净空与逐格检查的结果一致
+func TestClearance(t *testing.T) {
+	rnd := rand.New(rand.NewSource(21))
+	const rows, cols = 15, 15
+	mapData := make([][]int, rows)
+	for y := range mapData {
+		mapData[y] = make([]int, cols)
+		for x := range mapData[y] {
+			if rnd.Intn(8) == 0 {
+				mapData[y][x] = NODE_TYPE_OBSTACLE
+			}
+		}
+	}
+	g, err := NewGrid(rows, cols, mapData, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i <= 200; i++ {
+		for x := 0; x < cols; x++ {
+			for y := 0; y < rows; y++ {
+				if c, want := g.Clearance(x, y), bruteClearance(g, x, y); c != want {
+					t.Fatalf("edit %d cell %d,%d: clearance %d, want %d", i, x, y, c, want)
+				}
+			}
+		}
+		if err := g.SetWalkable(rnd.Intn(cols), rnd.Intn(rows), rnd.Intn(4) != 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
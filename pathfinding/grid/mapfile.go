@@ -0,0 +1,325 @@
+package grid
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+/*
+地图文件格式
+ASCII：每行一排格子，'.'可移动 '#'障碍 '='道路 'S'沼泽 'W'浅水
+CSV：每行一排格子，值为节点类型
+Moving AI：https://movingai.com/benchmarks/formats.html
+  type octile
+  height 5
+  width 8
+  map
+  ..@@....
+*/
+
+// ASCII地图字符与节点类型
+var (
+	asciiTypes = map[byte]int{
+		'.': NODE_TYPE_NORMAL,
+		'#': NODE_TYPE_OBSTACLE,
+		'=': NODE_TYPE_ROAD,
+		'S': NODE_TYPE_SWAMP,
+		'W': NODE_TYPE_WATER,
+	}
+	asciiChars = map[int]byte{
+		NODE_TYPE_NORMAL:   '.',
+		NODE_TYPE_OBSTACLE: '#',
+		NODE_TYPE_ROAD:     '=',
+		NODE_TYPE_SWAMP:    'S',
+		NODE_TYPE_WATER:    'W',
+	}
+)
+
+// Moving AI地图字符与节点类型
+// 该格式没有地形成本：S（沼泽）与普通地形相同，W（水）不可通行，
+// T（树）、O（地图外）按障碍处理，写入时道路、沼泽、浅水都按普通地形
+var (
+	movingAITypes = map[byte]int{
+		'.': NODE_TYPE_NORMAL,
+		'G': NODE_TYPE_NORMAL,
+		'S': NODE_TYPE_NORMAL,
+		'@': NODE_TYPE_OBSTACLE,
+		'O': NODE_TYPE_OBSTACLE,
+		'T': NODE_TYPE_OBSTACLE,
+		'W': NODE_TYPE_OBSTACLE,
+	}
+	movingAIChars = map[int]byte{
+		NODE_TYPE_NORMAL:   '.',
+		NODE_TYPE_OBSTACLE: '@',
+		NODE_TYPE_ROAD:     '.',
+		NODE_TYPE_SWAMP:    '.',
+		NODE_TYPE_WATER:    '.',
+	}
+)
+
+// 按扩展名读取地图文件：.map为Moving AI格式，.csv为CSV，其他按ASCII
+func LoadMap(name string) ([][]int, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".map":
+		return ReadMovingAI(f)
+	case ".csv":
+		return ReadCSV(f)
+	}
+	return ReadASCII(f)
+}
+
+func ReadASCII(r io.Reader) ([][]int, error) {
+	mapData := make([][]int, 0)
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if line == "" {
+			continue
+		}
+		row, err := parseRow(line, asciiTypes, len(mapData))
+		if err != nil {
+			return nil, err
+		}
+		mapData = append(mapData, row)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return mapData, nil
+}
+
+func WriteASCII(w io.Writer, mapData [][]int) error {
+	return writeRows(w, mapData, asciiChars)
+}
+
+func ReadCSV(r io.Reader) ([][]int, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	mapData := make([][]int, len(records))
+	for y, record := range records {
+		mapData[y] = make([]int, len(record))
+		for x, v := range record {
+			t, err := strconv.Atoi(v)
+			if err != nil {
+				return nil, fmt.Errorf("%w: cell %d,%d: %q is not a number", ErrInvalidMap, x, y, v)
+			}
+			mapData[y][x] = t
+		}
+	}
+	return mapData, nil
+}
+
+func WriteCSV(w io.Writer, mapData [][]int) error {
+	writer := csv.NewWriter(w)
+	for _, row := range mapData {
+		record := make([]string, len(row))
+		for x, t := range row {
+			record[x] = strconv.Itoa(t)
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+func ReadMovingAI(r io.Reader) ([][]int, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	height, width := -1, -1
+	// 文件头
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		if fields[0] == "map" {
+			break
+		}
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("%w: bad header line %q", ErrInvalidMap, scanner.Text())
+		}
+		switch fields[0] {
+		case "type":
+		case "height", "width":
+			n, err := strconv.Atoi(fields[1])
+			if err != nil || n <= 0 {
+				return nil, fmt.Errorf("%w: bad %s %q", ErrInvalidMap, fields[0], fields[1])
+			}
+			if fields[0] == "height" {
+				height = n
+			} else {
+				width = n
+			}
+		default:
+			return nil, fmt.Errorf("%w: unknown header %q", ErrInvalidMap, fields[0])
+		}
+	}
+	if height < 0 || width < 0 {
+		return nil, fmt.Errorf("%w: missing height or width", ErrInvalidMap)
+	}
+	// 地图数据
+	mapData := make([][]int, 0, height)
+	for len(mapData) < height && scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if len(line) != width {
+			return nil, fmt.Errorf("%w: row %d has %d cells, expected %d", ErrInvalidMap, len(mapData), len(line), width)
+		}
+		row, err := parseRow(line, movingAITypes, len(mapData))
+		if err != nil {
+			return nil, err
+		}
+		mapData = append(mapData, row)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(mapData) != height {
+		return nil, fmt.Errorf("%w: %d rows given, expected %d", ErrInvalidMap, len(mapData), height)
+	}
+	return mapData, nil
+}
+
+func WriteMovingAI(w io.Writer, mapData [][]int) error {
+	width := 0
+	if len(mapData) > 0 {
+		width = len(mapData[0])
+	}
+	_, err := fmt.Fprintf(w, "type octile\nheight %d\nwidth %d\nmap\n", len(mapData), width)
+	if err != nil {
+		return err
+	}
+	return writeRows(w, mapData, movingAIChars)
+}
+
+// 寻路场景，对应.scen文件的一行
+type Scenario struct {
+	Bucket int    `json:"bucket"`
+	Map    string `json:"map"`
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+	Start  Point  `json:"start"`
+	End    Point  `json:"end"`
+	// 最短路径长度，直线为1，对角为√2
+	Optimal float64 `json:"optimal"`
+}
+
+func ReadScenarios(r io.Reader) ([]Scenario, error) {
+	scenarios := make([]Scenario, 0)
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "version") {
+			continue
+		}
+		fields := strings.Fields(text)
+		if len(fields) != 9 {
+			return nil, fmt.Errorf("line %d: expects 9 fields, %d given", line, len(fields))
+		}
+		ints := make([]int, 0, 7)
+		for _, i := range []int{0, 2, 3, 4, 5, 6, 7} {
+			n, err := strconv.Atoi(fields[i])
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %q is not a number", line, fields[i])
+			}
+			ints = append(ints, n)
+		}
+		optimal, err := strconv.ParseFloat(fields[8], 64)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %q is not a number", line, fields[8])
+		}
+		scenarios = append(scenarios, Scenario{
+			Bucket:  ints[0],
+			Map:     fields[1],
+			Width:   ints[1],
+			Height:  ints[2],
+			Start:   Point{X: ints[3], Y: ints[4]},
+			End:     Point{X: ints[5], Y: ints[6]},
+			Optimal: optimal,
+		})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return scenarios, nil
+}
+
+func WriteScenarios(w io.Writer, scenarios []Scenario) error {
+	if _, err := fmt.Fprintln(w, "version 1"); err != nil {
+		return err
+	}
+	for _, s := range scenarios {
+		_, err := fmt.Fprintf(
+			w,
+			"%d\t%s\t%d\t%d\t%d\t%d\t%d\t%d\t%.8f\n",
+			s.Bucket,
+			s.Map,
+			s.Width,
+			s.Height,
+			s.Start.X,
+			s.Start.Y,
+			s.End.X,
+			s.End.Y,
+			s.Optimal,
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func LoadScenarios(name string) ([]Scenario, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ReadScenarios(f)
+}
+
+// 按字符表解析一行格子
+func parseRow(line string, types map[byte]int, y int) ([]int, error) {
+	row := make([]int, len(line))
+	for x := 0; x < len(line); x++ {
+		t, ok := types[line[x]]
+		if !ok {
+			return nil, fmt.Errorf("%w: unknown cell %q at %d,%d", ErrInvalidMap, line[x], x, y)
+		}
+		row[x] = t
+	}
+	return row, nil
+}
+
+// 按字符表写入格子，每排一行
+func writeRows(w io.Writer, mapData [][]int, chars map[int]byte) error {
+	bw := bufio.NewWriter(w)
+	for y, row := range mapData {
+		for x, t := range row {
+			c, ok := chars[t]
+			if !ok {
+				return fmt.Errorf("%w: node type %d at %d,%d has no symbol", ErrInvalidMap, t, x, y)
+			}
+			bw.WriteByte(c)
+		}
+		bw.WriteByte('\n')
+	}
+	return bw.Flush()
+}
@@ -0,0 +1,113 @@
+package grid
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// 地形地图写入每种格式后读回，内容不变
+// Moving AI没有地形成本，道路、沼泽、浅水读回为普通地形
+func TestMapFileRoundTrip(t *testing.T) {
+	mapData := [][]int{
+		{0, 2, 1, 1, 0},
+		{1, 3, 0, 4, 0},
+		{0, 0, 2, 1, 3},
+	}
+	noTerrain := make([][]int, len(mapData))
+	for y, row := range mapData {
+		noTerrain[y] = make([]int, len(row))
+		for x, v := range row {
+			if v != NODE_TYPE_OBSTACLE {
+				v = NODE_TYPE_NORMAL
+			}
+			noTerrain[y][x] = v
+		}
+	}
+	formats := []struct {
+		ext   string
+		write func(*bytes.Buffer, [][]int) error
+		read  func(*bytes.Buffer) ([][]int, error)
+		want  [][]int
+	}{
+		{".txt", func(b *bytes.Buffer, m [][]int) error { return WriteASCII(b, m) }, func(b *bytes.Buffer) ([][]int, error) { return ReadASCII(b) }, mapData},
+		{".csv", func(b *bytes.Buffer, m [][]int) error { return WriteCSV(b, m) }, func(b *bytes.Buffer) ([][]int, error) { return ReadCSV(b) }, mapData},
+		{".map", func(b *bytes.Buffer, m [][]int) error { return WriteMovingAI(b, m) }, func(b *bytes.Buffer) ([][]int, error) { return ReadMovingAI(b) }, noTerrain},
+	}
+	dir := t.TempDir()
+	for _, f := range formats {
+		var buf bytes.Buffer
+		if err := f.write(&buf, mapData); err != nil {
+			t.Fatalf("%s: %v", f.ext, err)
+		}
+		// LoadMap按扩展名选择格式
+		name := filepath.Join(dir, "map"+f.ext)
+		if err := os.WriteFile(name, buf.Bytes(), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		got, err := f.read(&buf)
+		if err != nil {
+			t.Fatalf("%s: %v", f.ext, err)
+		}
+		loaded, err := LoadMap(name)
+		if err != nil {
+			t.Fatalf("%s: %v", f.ext, err)
+		}
+		if !reflect.DeepEqual(got, f.want) || !reflect.DeepEqual(loaded, f.want) {
+			t.Fatalf("%s: read back %v, loaded %v, want %v", f.ext, got, loaded, f.want)
+		}
+	}
+}
+
+// 场景写入后读回，内容不变
+func TestScenariosRoundTrip(t *testing.T) {
+	scenarios := []Scenario{
+		{Bucket: 0, Map: "maps/a.map", Width: 9, Height: 7, Start: Point{X: 1, Y: 2}, End: Point{X: 8, Y: 6}, Optimal: 9.41421356},
+		{Bucket: 3, Map: "maps/a.map", Width: 9, Height: 7, Start: Point{X: 0, Y: 0}, End: Point{X: 4, Y: 0}, Optimal: 4},
+	}
+	var buf bytes.Buffer
+	if err := WriteScenarios(&buf, scenarios); err != nil {
+		t.Fatal(err)
+	}
+	got, err := ReadScenarios(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, scenarios) {
+		t.Fatalf("read back %v, want %v", got, scenarios)
+	}
+}
+
+// 未知字符、数字和不完整的文件头返回ErrInvalidMap
+func TestReadMapInvalid(t *testing.T) {
+	if _, err := ReadASCII(strings.NewReader("..\n.x\n")); !errors.Is(err, ErrInvalidMap) {
+		t.Errorf("ascii: %v", err)
+	}
+	if _, err := ReadCSV(strings.NewReader("0,1\n0,a\n")); !errors.Is(err, ErrInvalidMap) {
+		t.Errorf("csv: %v", err)
+	}
+	if _, err := ReadMovingAI(strings.NewReader("type octile\nwidth 2\nmap\n..\n")); !errors.Is(err, ErrInvalidMap) {
+		t.Errorf("moving ai header: %v", err)
+	}
+	if _, err := ReadMovingAI(strings.NewReader("type octile\nheight 2\nwidth 2\nmap\n..\n.\n")); !errors.Is(err, ErrInvalidMap) {
+		t.Errorf("moving ai row: %v", err)
+	}
+}
+
+// Moving AI格式中S（沼泽）可以通行且成本与普通地形相同，W（水）不可通行
+func TestReadMovingAITerrain(t *testing.T) {
+	mapData, err := ReadMovingAI(strings.NewReader("type octile\nheight 1\nwidth 6\nmap\n.GSWT@\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []int{NODE_TYPE_NORMAL, NODE_TYPE_NORMAL, NODE_TYPE_NORMAL, NODE_TYPE_OBSTACLE, NODE_TYPE_OBSTACLE, NODE_TYPE_OBSTACLE}
+	for x, v := range want {
+		if mapData[0][x] != v {
+			t.Errorf("cell %d: type %d, want %d", x, mapData[0][x], v)
+		}
+	}
+}
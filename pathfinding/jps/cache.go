@@ -0,0 +1,198 @@
+package jps
+
+import "sync"
+
+/*
+跳点缓存
+预先计算每个格子沿上、右、下、左4个直线方向：连续可行的格子数，以及到第一个跳点（有强迫邻居的格子）的步数，
+直线跳跃时直接查表，不必逐格检查强迫邻居
+订阅地图变化，格子可行性变化只影响所在的行列和相邻的行列，下次搜索开始时只重算这些行列
+地图已经修改、缓存还没有收到通知时（版本不同）搜索不使用缓存
+*/
+
+// 直线方向，与cache的下标对应
+var straightDirs = [4][2]int{
+	{0, -1}, // 上
+	{1, 0},  // 右
+	{0, 1},  // 下
+	{-1, 0}, // 左
+}
+
+type jumpCache struct {
+	mu   sync.RWMutex
+	grid *Grid
+	// 按方向、x*Rows+y存放
+	// 从格子开始（包括格子本身）沿方向连续可行的格子数
+	run [4][]int32
+	// 从格子开始沿方向到第一个跳点的步数，格子本身是跳点时为0，遇到障碍前没有跳点时为-1
+	next [4][]int32
+	// 缓存对应的地图版本
+	version int
+	// 等待重算的列和行
+	dirtyCols map[int]bool
+	dirtyRows map[int]bool
+	// 最近一次收到的变化的版本
+	notified int
+	cancel   func()
+}
+
+// 先订阅再构建，构建期间的变化会在下次搜索时重算
+func newJumpCache(g *Grid) *jumpCache {
+	c := &jumpCache{
+		grid:      g,
+		dirtyCols: make(map[int]bool),
+		dirtyRows: make(map[int]bool),
+	}
+	c.cancel = g.Subscribe(c.onChange)
+	g.RLock()
+	defer g.RUnlock()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for d := range straightDirs {
+		c.run[d] = make([]int32, g.Rows*g.Cols)
+		c.next[d] = make([]int32, g.Rows*g.Cols)
+	}
+	for x := 0; x < g.Cols; x++ {
+		c.buildCol(x)
+	}
+	for y := 0; y < g.Rows; y++ {
+		c.buildRow(y)
+	}
+	c.version = g.Version()
+	c.notified = max(c.notified, c.version)
+	return c
+}
+
+// 记录受影响的行列，不读取地图
+// 回调只持有缓存的锁，搜索先持有地图的读锁再持有缓存的锁，两者不会互相等待
+func (c *jumpCache) onChange(change Change) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.notified = max(c.notified, change.Version)
+	if !change.WalkableChanged() {
+		return
+	}
+	for i := -1; i <= 1; i++ {
+		c.dirtyCols[change.X+i] = true
+		c.dirtyRows[change.Y+i] = true
+	}
+}
+
+// 重算受影响的行列，缓存与地图一致时返回true并持有缓存的读锁，调用方持有地图的读锁
+func (c *jumpCache) acquire() bool {
+	c.mu.RLock()
+	if c.version == c.notified && len(c.dirtyCols) == 0 && len(c.dirtyRows) == 0 {
+		if c.version == c.grid.Version() {
+			return true
+		}
+		c.mu.RUnlock()
+		return false
+	}
+	c.mu.RUnlock()
+	c.mu.Lock()
+	if len(c.dirtyCols) > 0 || len(c.dirtyRows) > 0 {
+		for x := range c.dirtyCols {
+			if x >= 0 && x < c.grid.Cols {
+				c.buildCol(x)
+			}
+		}
+		for y := range c.dirtyRows {
+			if y >= 0 && y < c.grid.Rows {
+				c.buildRow(y)
+			}
+		}
+		clear(c.dirtyCols)
+		clear(c.dirtyRows)
+	}
+	c.version = c.notified
+	if c.version != c.grid.Version() {
+		c.mu.Unlock()
+		return false
+	}
+	c.mu.Unlock()
+	c.mu.RLock()
+	return true
+}
+
+func (c *jumpCache) release() {
+	c.mu.RUnlock()
+}
+
+// 重算一列的上、下方向
+func (c *jumpCache) buildCol(x int) {
+	c.buildLine(0, x, 0)
+	c.buildLine(2, x, c.grid.Rows-1)
+}
+
+// 重算一行的右、左方向
+func (c *jumpCache) buildRow(y int) {
+	c.buildLine(1, c.grid.Cols-1, y)
+	c.buildLine(3, 0, y)
+}
+
+// 从方向d的尽头x,y开始逆着方向递推
+func (c *jumpCache) buildLine(d, x, y int) {
+	g := c.grid
+	dx, dy := straightDirs[d][0], straightDirs[d][1]
+	var run, next int32 = 0, -1
+	for ; g.InBounds(x, y); x, y = x-dx, y-dy {
+		id := x*g.Rows + y
+		if !g.IsWalkable(x, y) {
+			run, next = 0, -1
+		} else {
+			run++
+			if c.forced(x, y, dx, dy) {
+				next = 0
+			} else if next >= 0 {
+				next++
+			}
+		}
+		c.run[d][id] = run
+		c.next[d][id] = next
+	}
+}
+
+// 沿dx,dy直线移动到x,y时是否有强迫邻居，与Searcher.jump的判断相同
+func (c *jumpCache) forced(x, y, dx, dy int) bool {
+	g := c.grid
+	if dx == 0 {
+		return !g.IsWalkable(x+1, y) && g.IsWalkable(x+1, y+dy) ||
+			!g.IsWalkable(x-1, y) && g.IsWalkable(x-1, y+dy)
+	}
+	return !g.IsWalkable(x, y+1) && g.IsWalkable(x+dx, y+1) ||
+		!g.IsWalkable(x, y-1) && g.IsWalkable(x+dx, y-1)
+}
+
+// 从可行的x,y沿直线方向跳跃，返回跳点坐标，没有跳点时返回false
+// 终点在直线上且不远于跳点时返回终点
+func (c *jumpCache) jump(x, y, dx, dy int, end Point) (int, int, bool) {
+	d := straightDir(dx, dy)
+	id := x*c.grid.Rows + y
+	run, next := int(c.run[d][id]), int(c.next[d][id])
+	// 终点到x,y沿方向的步数
+	steps := -1
+	if dx == 0 && end.X == x {
+		steps = (end.Y - y) * dy
+	} else if dy == 0 && end.Y == y {
+		steps = (end.X - x) * dx
+	}
+	if steps >= 0 && steps < run && (next < 0 || steps <= next) {
+		return end.X, end.Y, true
+	}
+	if next < 0 {
+		return 0, 0, false
+	}
+	return x + dx*next, y + dy*next, true
+}
+
+func straightDir(dx, dy int) int {
+	switch {
+	case dy < 0:
+		return 0
+	case dx > 0:
+		return 1
+	case dy > 0:
+		return 2
+	}
+	return 3
+}
@@ -0,0 +1,57 @@
+package jps
+
+import (
+	"context"
+	"math/rand"
+	"testing"
+)
+
+// 逐格跳跃的结果，不使用缓存
+func findUncached(r *Jps, start, end Point) (*Path, error) {
+	r.grid.RLock()
+	defer r.grid.RUnlock()
+	s := r.NewSearcher()
+	node, err := s.find(context.Background(), &Node{X: start.X, Y: start.Y}, &Node{X: end.X, Y: end.Y})
+	if err != nil || node == nil {
+		return nil, ErrNoPath
+	}
+	return newPath(node, len(s.closeList)), nil
+}
+
+// 地图修改后，使用缓存的路径成本与逐格跳跃相同
+func TestJumpCacheFollowsGrid(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	const rows, cols = 24, 32
+	mapData := make([][]int, rows)
+	for y := range mapData {
+		mapData[y] = make([]int, cols)
+		for x := range mapData[y] {
+			if rnd.Intn(4) == 0 {
+				mapData[y][x] = NODE_TYPE_OBSTACLE
+			}
+		}
+	}
+	r, err := NewJps(mapData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 300; i++ {
+		x, y := rnd.Intn(cols), rnd.Intn(rows)
+		if err := r.Grid().SetWalkable(x, y, rnd.Intn(3) != 0); err != nil {
+			t.Fatal(err)
+		}
+		start := Point{X: rnd.Intn(cols), Y: rnd.Intn(rows)}
+		end := Point{X: rnd.Intn(cols), Y: rnd.Intn(rows)}
+		if r.Grid().Type(start.X, start.Y) == NODE_TYPE_OBSTACLE || r.Grid().Type(end.X, end.Y) == NODE_TYPE_OBSTACLE {
+			continue
+		}
+		want, wantErr := findUncached(r, start, end)
+		got, err := r.FindPath(&Node{X: start.X, Y: start.Y}, &Node{X: end.X, Y: end.Y})
+		if (err == nil) != (wantErr == nil) {
+			t.Fatalf("%v -> %v: cached err %v, uncached err %v", start, end, err, wantErr)
+		}
+		if err == nil && got.Cost != want.Cost {
+			t.Fatalf("%v -> %v: cached cost %d, uncached cost %d", start, end, got.Cost, want.Cost)
+		}
+	}
+}
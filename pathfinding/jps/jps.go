@@ -0,0 +1,606 @@
+package jps
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"pathfinding/grid"
+	"sort"
+	"sync"
+)
+
+/*
+地图从左上角开始，水平x 垂直y
+  y
+x 0,0 1,0 2,0
+  0,1 1,1 2,1
+  0,2 1,2 2,2
+跳点搜索不支持地形成本，地图中除障碍外的格子都按普通格子处理
+默认允许斜向穿过两个障碍之间的拐角，NoCornerCut为true时斜向移动的两侧都必须可行，
+与Moving AI场景和A*的MOVEMENT_EIGHT_NO_OBSTACLE一致，这时强迫邻居的判断不同，不使用跳点缓存
+*/
+
+type (
+	Grid   = grid.Grid
+	Change = grid.Change
+	Point  = grid.Point
+)
+
+// 节点
+type Node struct {
+	// 坐标
+	X int
+	Y int
+	// 移动成本
+	F int
+	G int
+	H int
+	// 父节点
+	Parent *Node
+	// 类型
+	Type int
+	// 状态
+	State int
+	// 所属搜索批次，与当前批次不同时搜索状态视为无效
+	search int
+}
+
+type Jps struct {
+	// 启发算法
+	Heuristic func(node, end *Node) int
+	// 地图大小
+	Rows int // y
+	Cols int // x
+	// 单次搜索最多扩展的节点数，0表示不限
+	MaxExpanded int
+	// 斜向移动时两侧都必须可行
+	NoCornerCut bool
+	// 地图
+	grid *Grid
+	// 对角相邻坐标
+	neighborPos [][]int
+	// 直线方向的跳点缓存，跟随地图变化
+	cache *jumpCache
+	// 寻路器池
+	pool sync.Pool
+}
+
+// 移动成本和节点类型与地图相同
+const (
+	COST_STRAIGHT = grid.COST_STRAIGHT
+	COST_DIAGONAL = grid.COST_DIAGONAL
+
+	NODE_TYPE_NORMAL   = grid.NODE_TYPE_NORMAL
+	NODE_TYPE_OBSTACLE = grid.NODE_TYPE_OBSTACLE
+)
+
+// 节点状态
+const (
+	NODE_STATE_CLOSED = iota - 1
+	NODE_STATE_NORMAL
+	NODE_STATE_OPENED
+)
+
+// 按数据生成寻路器，地图大小取自mapData，启发算法为Diagonal
+// 数据为空、每行长度不同或含有未知节点类型时返回ErrInvalidMap
+func NewJps(mapData [][]int) (*Jps, error) {
+	r := &Jps{
+		Rows:      len(mapData),
+		Heuristic: Diagonal,
+	}
+	if r.Rows > 0 {
+		r.Cols = len(mapData[0])
+	}
+	if err := r.Init(mapData); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// 按Rows、Cols初始化地图，数据与地图大小不符时返回错误
+func (r *Jps) Init(mapData [][]int) error {
+	g, err := grid.NewGrid(r.Rows, r.Cols, mapData, nil)
+	if err != nil {
+		return err
+	}
+	r.grid = g
+	if r.cache != nil {
+		r.cache.cancel()
+	}
+	r.cache = newJumpCache(g)
+	r.neighborPos = [][]int{
+		{0, -1},  // 上
+		{1, -1},  // 右上
+		{1, 0},   // 右
+		{1, 1},   // 右下
+		{0, 1},   // 下
+		{-1, 1},  // 左下
+		{-1, 0},  // 左
+		{-1, -1}, // 左上
+	}
+	return nil
+}
+
+// 启发算法，未指定时为Diagonal
+func (r *Jps) heuristic(node, end *Node) int {
+	if r.Heuristic != nil {
+		return r.Heuristic(node, end)
+	}
+	return Diagonal(node, end)
+}
+
+// 寻路，可被多个goroutine同时调用
+// 路径只包含跳点
+func (r *Jps) FindPath(start, end *Node) (*Path, error) {
+	s := r.Acquire()
+	defer r.Release(s)
+	return s.FindPath(start, end)
+}
+
+// 带取消和预算的寻路，可被多个goroutine同时调用
+// ctx取消、超时或扩展节点数超过MaxExpanded时，返回离终点最近的部分路径和ErrBudget
+func (r *Jps) FindPathContext(ctx context.Context, start, end *Node) (*Path, error) {
+	s := r.Acquire()
+	defer r.Release(s)
+	return s.FindPathContext(ctx, start, end)
+}
+
+func (r *Searcher) FindPath(start, end *Node) (*Path, error) {
+	return r.FindPathContext(context.Background(), start, end)
+}
+
+func (r *Searcher) FindPathContext(ctx context.Context, start, end *Node) (*Path, error) {
+	r.jps.grid.RLock()
+	defer r.jps.grid.RUnlock()
+	if err := checkEndpoints(r.jps.grid, start, end); err != nil {
+		return nil, err
+	}
+	// 缓存与地图一致时使用缓存，否则逐格跳跃
+	if !r.jps.NoCornerCut && r.jps.cache.acquire() {
+		r.cache = r.jps.cache
+		defer func() {
+			r.cache.release()
+			r.cache = nil
+		}()
+	}
+	node, err := r.find(ctx, start, end)
+	if err != nil {
+		path := newPath(node, len(r.closeList))
+		path.Partial = true
+		return path, err
+	}
+	if node == nil {
+		return nil, ErrNoPath
+	}
+	return newPath(node, len(r.closeList)), nil
+}
+
+// 搜索，返回终点节点，沿Parent回溯可得路径
+// 预算用完时返回已扩展节点中离终点最近的节点和错误
+func (r *Searcher) find(ctx context.Context, start, end *Node) (*Node, error) {
+	r.reset()
+	r.start = r.getNode(start.X, start.Y)
+	r.end = r.getNode(end.X, end.Y)
+	r.start.H = r.jps.heuristic(r.start, r.end)
+	r.start.F = r.start.H
+	closest := r.start
+	// 先把开始节点放进开放列表
+	r.openListAppend(r.start)
+	for len(r.openList) > 0 {
+		if err := r.checkBudget(ctx, len(r.closeList)); err != nil {
+			return closest, err
+		}
+		node := r.openListPop()
+		// 判断当前节点是否是终点
+		if r.isEnd(node) {
+			return node, nil
+		}
+		if node.H < closest.H || node.H == closest.H && node.G < closest.G {
+			closest = node
+		}
+		// 找开放列表的第一个节点的相邻节点
+		neighbors := r.findNeighbors(node)
+		for _, neighbor := range neighbors {
+			jump := r.jump(neighbor, node)
+			// 无跳点 || 节点已被关闭
+			if jump == nil || jump.isClosed() {
+				continue
+			}
+			// 当前节点的成本
+			// 跳点的x,y
+			g, x, y := node.G, jump.X, jump.Y
+			// 判断移动方式是水平（或垂直）、对角，计算成本
+			if x == node.X {
+				g += abs(y-node.Y) * COST_STRAIGHT
+			} else if y == node.Y {
+				g += abs(x-node.X) * COST_STRAIGHT
+			} else {
+				g += abs(x-node.X) * COST_DIAGONAL
+			}
+			if !jump.isOpened() || g < jump.G {
+				jump.G = g
+				jump.H = r.jps.heuristic(jump, r.end)
+				jump.F = jump.G + jump.H
+				jump.Parent = node
+				// 优化逻辑，跳点是否是终点
+				if r.isEnd(jump) {
+					return jump, nil
+				}
+				if !jump.isOpened() {
+					r.openListAppend(jump)
+				}
+			}
+		}
+		// 当前节点放进关闭列表
+		r.closeListAppend(node)
+		// 更新开放列表顺序
+		r.openListSort()
+	}
+	return nil, nil
+}
+
+// 检查搜索预算，每扩展64个节点检查一次ctx
+func (r *Searcher) checkBudget(ctx context.Context, expanded int) error {
+	if r.jps.MaxExpanded > 0 && expanded >= r.jps.MaxExpanded {
+		return ErrBudget
+	}
+	if expanded%64 == 0 {
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%w: %w", ErrBudget, ctx.Err())
+		default:
+		}
+	}
+	return nil
+}
+
+// 跳点函数
+// 判断当前点是否满足跳点条件
+func (r *Searcher) jump(node, parent *Node) *Node {
+	// 是终点，直接返回
+	if r.isEnd(node) {
+		return node
+	}
+	if r.jps.NoCornerCut {
+		return r.jumpNoCornerCut(node, parent)
+	}
+	x, y := node.X, node.Y
+	dx, dy := r.direction(node, parent)
+	// 直线移动时查跳点缓存
+	if r.cache != nil && (dx == 0 || dy == 0) {
+		jx, jy, ok := r.cache.jump(x, y, dx, dy, Point{X: r.end.X, Y: r.end.Y})
+		if !ok {
+			return nil
+		}
+		return r.getNode(jx, jy)
+	}
+	// 对角移动
+	if dx != 0 && dy != 0 {
+		// [左|右]不能走 && [左上|左下|右上|右下]能走
+		if !r.isWalkable(x-dx, y) && r.isWalkable(x-dx, y+dy) {
+			return node
+		}
+		// [上|下]不能走 && [左上|右上|左下|右下]能走
+		if !r.isWalkable(x, y-dy) && r.isWalkable(x+dx, y-dy) {
+			return node
+		}
+		// 递归查找方向[上|下]继续查找
+		if r.isWalkable(x+dx, y) && r.jump(r.getNode(x+dx, y), node) != nil {
+			return node
+		}
+		// 递归查找方向[左|右]继续查找
+		if r.isWalkable(x, y+dy) && r.jump(r.getNode(x, y+dy), node) != nil {
+			return node
+		}
+	} else if dx == 0 { // 垂直移动
+		// 右不能走 && [右下|右上]能走
+		if !r.isWalkable(x+1, y) && r.isWalkable(x+1, y+dy) {
+			return node
+		}
+		// 左不能走 && [左上|左下]能走
+		if !r.isWalkable(x-1, y) && r.isWalkable(x-1, y+dy) {
+			return node
+		}
+	} else { // 水平移动
+		// 下不能走 && [左下|右下]能走
+		if !r.isWalkable(x, y+1) && r.isWalkable(x+dx, y+1) {
+			return node
+		}
+		// 上不能走 && [左上|右上]能走
+		if !r.isWalkable(x, y-1) && r.isWalkable(x+dx, y-1) {
+			return node
+		}
+	}
+	// 递归查找方向[左上|左下|右上|右下]继续查找
+	if r.isWalkable(x+dx, y+dy) {
+		if next := r.jump(r.getNode(x+dx, y+dy), node); next != nil {
+			return next
+		}
+	}
+	// 无强迫邻居（当前节点不是跳点）或到达边界
+	return nil
+}
+
+// 不允许斜穿拐角时的跳点函数
+// 直线移动时侧面的格子可行、而它后方的格子是障碍，侧面格子只能从当前节点到达，当前节点是跳点
+// 对角移动时两侧都可行才能继续
+func (r *Searcher) jumpNoCornerCut(node, parent *Node) *Node {
+	x, y := node.X, node.Y
+	dx, dy := r.direction(node, parent)
+	if dx != 0 && dy != 0 {
+		// 从当前节点沿直线方向能找到跳点
+		if r.isWalkable(x+dx, y) && r.jump(r.getNode(x+dx, y), node) != nil ||
+			r.isWalkable(x, y+dy) && r.jump(r.getNode(x, y+dy), node) != nil {
+			return node
+		}
+		if !r.isWalkable(x+dx, y) || !r.isWalkable(x, y+dy) {
+			return nil
+		}
+	} else {
+		// 侧面方向，水平移动时为上下，垂直移动时为左右
+		sx, sy := dy, dx
+		if r.isWalkable(x+sx, y+sy) && !r.isWalkable(x+sx-dx, y+sy-dy) ||
+			r.isWalkable(x-sx, y-sy) && !r.isWalkable(x-sx-dx, y-sy-dy) {
+			return node
+		}
+	}
+	if !r.isWalkable(x+dx, y+dy) {
+		return nil
+	}
+	return r.jump(r.getNode(x+dx, y+dy), node)
+}
+
+// 查找相邻节点位置
+func (r *Searcher) findNeighbors(node *Node) []*Node {
+	neighbors := make([]*Node, 0)
+	// 第一次移动
+	if node.Parent == nil {
+		for _, v := range r.jps.neighborPos {
+			x, y := node.X+v[0], node.Y+v[1]
+			// 检测节点是否非法
+			if !r.isWalkable(x, y) || !r.canCross(node.X, node.Y, v[0], v[1]) {
+				continue
+			}
+			neighbors = append(neighbors, r.getNode(x, y))
+		}
+	} else if r.jps.NoCornerCut {
+		neighbors = r.neighborsNoCornerCut(node)
+	} else {
+		// 计算当前节点位于父节点的方向：水平、垂直和对角方向
+		x, y := node.X, node.Y
+		dx, dy := r.direction(node, node.Parent)
+		// 移动方向上的下一个
+		if r.isWalkable(x+dx, y+dy) {
+			neighbors = append(neighbors, r.getNode(x+dx, y+dy))
+		}
+		// 对角移动
+		if dx != 0 && dy != 0 {
+			// [左|右]能走
+			if r.isWalkable(x+dx, y) {
+				neighbors = append(neighbors, r.getNode(x+dx, y))
+			}
+			// [上|下]能走
+			if r.isWalkable(x, y+dy) {
+				neighbors = append(neighbors, r.getNode(x, y+dy))
+			}
+			// [左|右]不能走 && [左上|左下|右上|右下]能走
+			if !r.isWalkable(x-dx, y) && r.isWalkable(x-dx, y+dy) {
+				neighbors = append(neighbors, r.getNode(x-dx, y+dy))
+			}
+			// [上|下]不能走 && [左上|右上|左下|右下]能走
+			if !r.isWalkable(x, y-dy) && r.isWalkable(x+dx, y-dy) {
+				neighbors = append(neighbors, r.getNode(x+dx, y-dy))
+			}
+		} else if dx == 0 { // 垂直移动
+			// 右不能走 && [右下|右上]能走
+			if !r.isWalkable(x+1, y) && r.isWalkable(x+1, y+dy) {
+				neighbors = append(neighbors, r.getNode(x+1, y+dy))
+			}
+			// 左不能走 && [左上|左下]能走
+			if !r.isWalkable(x-1, y) && r.isWalkable(x-1, y+dy) {
+				neighbors = append(neighbors, r.getNode(x-1, y+dy))
+			}
+		} else { // 水平移动
+			// 下不能走 && [左下|右下]能走
+			if !r.isWalkable(x, y+1) && r.isWalkable(x+dx, y+1) {
+				neighbors = append(neighbors, r.getNode(x+dx, y+1))
+			}
+			// 上不能走 && [左上|右上]能走
+			if !r.isWalkable(x, y-1) && r.isWalkable(x+dx, y-1) {
+				neighbors = append(neighbors, r.getNode(x+dx, y-1))
+			}
+		}
+	}
+	return neighbors
+}
+
+// 不允许斜穿拐角时按来的方向裁剪相邻节点
+func (r *Searcher) neighborsNoCornerCut(node *Node) []*Node {
+	neighbors := make([]*Node, 0)
+	x, y := node.X, node.Y
+	dx, dy := r.direction(node, node.Parent)
+	add := func(nx, ny int) {
+		if r.isWalkable(nx, ny) && r.canCross(x, y, nx-x, ny-y) {
+			neighbors = append(neighbors, r.getNode(nx, ny))
+		}
+	}
+	// 对角移动：两个直线方向和对角方向
+	if dx != 0 && dy != 0 {
+		add(x+dx, y)
+		add(x, y+dy)
+		add(x+dx, y+dy)
+		return neighbors
+	}
+	// 直线移动：前方，以及后方是障碍时的侧面和斜前方（强迫邻居）
+	add(x+dx, y+dy)
+	sx, sy := dy, dx
+	for _, k := range []int{1, -1} {
+		if !r.isWalkable(x+k*sx-dx, y+k*sy-dy) {
+			add(x+k*sx, y+k*sy)
+			add(x+k*sx+dx, y+k*sy+dy)
+		}
+	}
+	return neighbors
+}
+
+// 不允许斜穿拐角时，对角移动的两侧都必须可行
+func (r *Searcher) canCross(x, y, dx, dy int) bool {
+	if !r.jps.NoCornerCut || dx == 0 || dy == 0 {
+		return true
+	}
+	return r.isWalkable(x+dx, y) && r.isWalkable(x, y+dy)
+}
+
+// 地图，修改格子或订阅变化
+func (r *Jps) Grid() *Grid {
+	return r.grid
+}
+
+func (r *Searcher) isWalkable(x, y int) bool {
+	return r.jps.grid.IsWalkable(x, y)
+}
+
+func (r *Searcher) isEnd(node *Node) bool {
+	return node.X == r.end.X && node.Y == r.end.Y
+}
+
+func (node *Node) isWalkable() bool {
+	return node.Type != NODE_TYPE_OBSTACLE
+}
+
+func (node *Node) isOpened() bool {
+	return node.State == NODE_STATE_OPENED
+}
+
+func (node *Node) isClosed() bool {
+	return node.State == NODE_STATE_CLOSED
+}
+
+func (r *Searcher) openListAppend(node *Node) {
+	node.State = NODE_STATE_OPENED
+	r.openList = append(r.openList, node)
+}
+
+func (r *Searcher) openListPop() *Node {
+	s := r.openList
+	if len(s) == 0 {
+		return nil
+	}
+	v := s[0]
+	s[0] = nil
+	s = s[1:]
+	r.openList = s
+	return v
+}
+
+func (r *Searcher) openListSort() {
+	sort.Slice(r.openList, func(i, j int) bool {
+		return r.openList[i].F < r.openList[j].F
+	})
+}
+
+func (r *Searcher) closeListAppend(node *Node) {
+	node.State = NODE_STATE_CLOSED
+	r.closeList = append(r.closeList, node)
+}
+
+// 打印路径、导航图和上一次搜索的开放、关闭列表
+func (a *Searcher) Print(path *Path, mapData [][]int) {
+	fmt.Println("导航路径：")
+	onPath := make(map[Point]bool, len(path.Points))
+	for i := len(path.Points) - 1; i >= 0; i-- {
+		node := a.getNode(path.Points[i].X, path.Points[i].Y)
+		fmt.Printf("x,y: %d,%d cost: f%d h%d g%d \n", node.X, node.Y, node.F, node.H, node.G)
+		onPath[path.Points[i]] = true
+	}
+	fmt.Println("导航图：")
+	for i := 0; i < len(mapData); i++ {
+		for j := 0; j < len(mapData[i]); j++ {
+			if onPath[Point{X: j, Y: i}] {
+				fmt.Print("* ")
+			} else {
+				fmt.Print(mapData[i][j], " ")
+			}
+		}
+		fmt.Print("\n")
+	}
+	fmt.Println("准备扫描节点：")
+	for _, node := range a.openList {
+		fmt.Printf("x,y: %d,%d \n", node.X, node.Y)
+	}
+	fmt.Println("已扫描节点：")
+	for _, node := range a.closeList {
+		fmt.Printf("x,y: %d,%d \n", node.X, node.Y)
+	}
+}
+
+// 曼哈顿
+func Manhattan(node, end *Node) int {
+	x := abs(node.X - end.X)
+	y := abs(node.Y - end.Y)
+	return (x + y) * COST_STRAIGHT
+}
+
+// 对角线
+func Diagonal(node, end *Node) int {
+	x := abs(node.X - end.X)
+	y := abs(node.Y - end.Y)
+	min := min(x, y)
+	return min*COST_DIAGONAL + abs(x-y)*COST_STRAIGHT
+}
+
+// 欧几里得
+func Euclidean(node, end *Node) int {
+	x := abs(node.X - end.X)
+	y := abs(node.Y - end.Y)
+	v := float64(x)*float64(x) + float64(y)*float64(y)
+	return int(math.Sqrt(v) * COST_STRAIGHT)
+}
+
+// 45度角
+func Octile(node, end *Node) int {
+	x := abs(node.X - end.X)
+	y := abs(node.Y - end.Y)
+	min, max := min(x, y), max(x, y)
+	return (COST_DIAGONAL-COST_STRAIGHT)*min + max
+}
+
+// 计算移动方向
+// 该函数计算结果：0,1（垂直移动）、1,0（水平移动）、1,1 | -1,n | n,-1（对角移动）
+func (r *Searcher) direction(node, parent *Node) (int, int) {
+	x, y := node.X, node.Y
+	px, py := parent.X, parent.Y
+	dx := (x - px) / max(abs(x-px), 1)
+	dy := (y - py) / max(abs(y-py), 1)
+	// fmt.Printf(
+	// 	"计算方向 px,py: %d,%d x,y:%d,%d dx,dy:%d,%d \n",
+	// 	px,
+	// 	py,
+	// 	x,
+	// 	y,
+	// 	dx,
+	// 	dy,
+	// )
+	return dx, dy
+}
+
+func abs(n int) int {
+	y := n >> 63
+	return (n ^ y) - y
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	} else {
+		return b
+	}
+}
+
+func max(a, b int) int {
+	if a > b {
+		return a
+	} else {
+		return b
+	}
+}
@@ -0,0 +1,322 @@
+package jps
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"pathfinding/astar"
+	"sync"
+	"testing"
+	"time"
+)
+
+// 多个goroutine同时在同一个Jps上寻路，结果与依次寻路相同，用go test -race检查数据竞争
+func TestFindPathConcurrent(t *testing.T) {
+	rnd := rand.New(rand.NewSource(13))
+	const rows, cols = 40, 40
+	mapData := make([][]int, rows)
+	for y := range mapData {
+		mapData[y] = make([]int, cols)
+		for x := range mapData[y] {
+			if rnd.Intn(5) == 0 {
+				mapData[y][x] = NODE_TYPE_OBSTACLE
+			}
+		}
+	}
+	r, err := NewJps(mapData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	queries := make([][2]*Node, 400)
+	want := make([]int, len(queries))
+	for i := range queries {
+		queries[i] = [2]*Node{{X: rnd.Intn(cols), Y: rnd.Intn(rows)}, {X: rnd.Intn(cols), Y: rnd.Intn(rows)}}
+		want[i] = pathCost(r.FindPath(queries[i][0], queries[i][1]))
+	}
+	var wg sync.WaitGroup
+	errs := make(chan error, 8)
+	for w := 0; w < 8; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := w; i < len(queries); i += 8 {
+				if cost := pathCost(r.FindPath(queries[i][0], queries[i][1])); cost != want[i] {
+					errs <- fmt.Errorf("%v -> %v: cost %d, sequential %d", queries[i][0], queries[i][1], cost, want[i])
+					return
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatal(err)
+	}
+}
+
+// 多个goroutine同时寻路和修改地图，用go test -race检查数据竞争
+func TestFindPathConcurrentEdits(t *testing.T) {
+	rnd := rand.New(rand.NewSource(13))
+	const rows, cols = 40, 40
+	mapData := make([][]int, rows)
+	for y := range mapData {
+		mapData[y] = make([]int, cols)
+		for x := range mapData[y] {
+			if rnd.Intn(5) == 0 {
+				mapData[y][x] = NODE_TYPE_OBSTACLE
+			}
+		}
+	}
+	r, err := NewJps(mapData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan struct{})
+	var edits sync.WaitGroup
+	for w := 0; w < 2; w++ {
+		edits.Add(1)
+		go func(seed int64) {
+			defer edits.Done()
+			rnd := rand.New(rand.NewSource(seed))
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				r.Grid().SetWalkable(rnd.Intn(cols), rnd.Intn(rows), rnd.Intn(3) != 0)
+			}
+		}(int64(w))
+	}
+	var searches sync.WaitGroup
+	errs := make(chan error, 8)
+	for w := 0; w < 8; w++ {
+		searches.Add(1)
+		go func(seed int64) {
+			defer searches.Done()
+			rnd := rand.New(rand.NewSource(seed))
+			for i := 0; i < 50; i++ {
+				start := &Node{X: rnd.Intn(cols), Y: rnd.Intn(rows)}
+				end := &Node{X: rnd.Intn(cols), Y: rnd.Intn(rows)}
+				path, err := r.FindPathContext(context.Background(), start, end)
+				if err == nil && (path.Points[0] != Point{X: start.X, Y: start.Y} || path.Points[len(path.Points)-1] != Point{X: end.X, Y: end.Y}) {
+					errs <- fmt.Errorf("path %v does not run from %v to %v", path.Points, start, end)
+					return
+				}
+				if err != nil && !errors.Is(err, ErrNoPath) && !errors.Is(err, ErrStartBlocked) && !errors.Is(err, ErrEndBlocked) {
+					errs <- err
+					return
+				}
+			}
+		}(int64(100 + w))
+	}
+	searches.Wait()
+	close(done)
+	edits.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatal(err)
+	}
+}
+
+// 路径成本，找不到路径时为-1
+func pathCost(path *Path, err error) int {
+	if err != nil {
+		return -1
+	}
+	return path.Cost
+}
+
+// 越界、起止点是障碍、无法到达时返回对应的错误，成功时路径从起点到终点
+func TestFindPathErrors(t *testing.T) {
+	mapData := [][]int{
+		{0, 0, 0, 1, 0},
+		{0, 1, 0, 1, 0},
+		{0, 1, 0, 1, 1},
+	}
+	r, err := NewJps(mapData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		start, end Point
+		err        error
+	}{
+		{Point{X: -1, Y: 0}, Point{X: 0, Y: 0}, ErrOutOfBounds},
+		{Point{X: 0, Y: 0}, Point{X: 5, Y: 0}, ErrOutOfBounds},
+		{Point{X: 1, Y: 1}, Point{X: 0, Y: 0}, ErrStartBlocked},
+		{Point{X: 0, Y: 0}, Point{X: 3, Y: 0}, ErrEndBlocked},
+		{Point{X: 0, Y: 0}, Point{X: 4, Y: 1}, ErrNoPath},
+	}
+	for _, tt := range tests {
+		_, err := r.FindPath(&Node{X: tt.start.X, Y: tt.start.Y}, &Node{X: tt.end.X, Y: tt.end.Y})
+		if !errors.Is(err, tt.err) {
+			t.Errorf("%v -> %v: %v, want %v", tt.start, tt.end, err, tt.err)
+		}
+	}
+	path, err := r.FindPath(&Node{X: 0, Y: 0}, &Node{X: 2, Y: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	last := path.Points[len(path.Points)-1]
+	if path.Points[0] != (Point{X: 0, Y: 0}) || last != (Point{X: 2, Y: 2}) || path.Cost != 34 || path.Expanded == 0 {
+		t.Fatalf("path %v cost %d expanded %d", path.Points, path.Cost, path.Expanded)
+	}
+}
+
+// 空地图、每行长度不同、未知节点类型返回ErrInvalidMap，未设置启发算法时按Diagonal寻路
+func TestNewJps(t *testing.T) {
+	for _, mapData := range [][][]int{
+		nil,
+		{{0, 0}, {0}},
+		{{0, 9}},
+	} {
+		if _, err := NewJps(mapData); !errors.Is(err, ErrInvalidMap) {
+			t.Errorf("%v: %v, want ErrInvalidMap", mapData, err)
+		}
+	}
+	mapData := [][]int{
+		{0, 0, 0},
+		{0, 1, 0},
+	}
+	r, err := NewJps(mapData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := r.FindPath(&Node{X: 0, Y: 0}, &Node{X: 2, Y: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r = &Jps{Rows: 2, Cols: 3}
+	if err := r.Init(mapData); err != nil {
+		t.Fatal(err)
+	}
+	path, err := r.FindPath(&Node{X: 0, Y: 0}, &Node{X: 2, Y: 1})
+	if err != nil || path.Cost != want.Cost {
+		t.Fatalf("no heuristic: %v, %v, want cost %d", path, err, want.Cost)
+	}
+}
+
+// 扩展节点数用完、ctx取消或超时时返回部分路径和ErrBudget，部分路径从起点出发
+func TestFindPathBudget(t *testing.T) {
+	// 竖墙交替在最下和最上一行留缺口，路径需要来回绕行
+	mapData := make([][]int, 20)
+	for y := range mapData {
+		mapData[y] = make([]int, 20)
+		for x := 1; x < 20; x += 2 {
+			if x%4 == 1 && y != 19 || x%4 == 3 && y != 0 {
+				mapData[y][x] = NODE_TYPE_OBSTACLE
+			}
+		}
+	}
+	r, err := NewJps(mapData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	start, end := &Node{X: 0, Y: 0}, &Node{X: 18, Y: 0}
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	expired, cancel := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancel()
+	tests := []struct {
+		name        string
+		ctx         context.Context
+		maxExpanded int
+		err         error
+	}{
+		{"max expanded", context.Background(), 10, ErrBudget},
+		{"cancelled", cancelled, 0, context.Canceled},
+		{"deadline", expired, 0, context.DeadlineExceeded},
+	}
+	for _, tt := range tests {
+		r.MaxExpanded = tt.maxExpanded
+		path, err := r.FindPathContext(tt.ctx, start, end)
+		if !errors.Is(err, ErrBudget) || !errors.Is(err, tt.err) {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if path == nil || !path.Partial || path.Points[0] != (Point{X: 0, Y: 0}) || path.Expanded > tt.maxExpanded {
+			t.Fatalf("%s: partial path %+v", tt.name, path)
+		}
+	}
+	r.MaxExpanded = 0
+	path, err := r.FindPathContext(context.Background(), start, end)
+	if err != nil || path.Partial || path.Points[len(path.Points)-1] != (Point{X: 18, Y: 0}) {
+		t.Fatalf("unlimited: %v, %+v", err, path)
+	}
+}
+
+// 随机地图上跳点搜索与A*的最短成本相同，两种拐角规则分别对应A*的MOVEMENT_EIGHT和MOVEMENT_EIGHT_NO_OBSTACLE，
+// 不允许斜穿拐角时路径的每一步两侧都可行
+func TestFindPathMatchesAStar(t *testing.T) {
+	rnd := rand.New(rand.NewSource(9))
+	for round := 0; round < 50; round++ {
+		const rows, cols = 24, 24
+		mapData := make([][]int, rows)
+		for y := range mapData {
+			mapData[y] = make([]int, cols)
+			for x := range mapData[y] {
+				if rnd.Intn(4) == 0 {
+					mapData[y][x] = NODE_TYPE_OBSTACLE
+				}
+			}
+		}
+		for _, noCornerCut := range []bool{false, true} {
+			r, err := NewJps(mapData)
+			if err != nil {
+				t.Fatal(err)
+			}
+			r.NoCornerCut = noCornerCut
+			a, err := astar.NewAStar(mapData, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			a.Movement = astar.MOVEMENT_EIGHT
+			if noCornerCut {
+				a.Movement = astar.MOVEMENT_EIGHT_NO_OBSTACLE
+			}
+			for i := 0; i < 20; i++ {
+				start, end := &Node{X: rnd.Intn(cols), Y: rnd.Intn(rows)}, &Node{X: rnd.Intn(cols), Y: rnd.Intn(rows)}
+				path, err := r.FindPath(start, end)
+				want, wantErr := a.FindPath(&astar.Node{X: start.X, Y: start.Y}, &astar.Node{X: end.X, Y: end.Y})
+				if (err == nil) != (wantErr == nil) || err == nil && path.Cost != want.Cost {
+					t.Fatalf("round %d no corner cut %v %v -> %v: jps %v %v, astar %v %v",
+						round, noCornerCut, start, end, path, err, want, wantErr)
+				}
+				if err == nil {
+					checkSteps(t, r, path, a.Movement)
+				}
+			}
+		}
+	}
+}
+
+// 沿跳点之间的直线或对角线段逐格检查移动是否合法
+func checkSteps(t *testing.T, r *Jps, path *Path, movement int) {
+	t.Helper()
+	g := r.Grid()
+	g.RLock()
+	defer g.RUnlock()
+	for i := 1; i < len(path.Points); i++ {
+		a, b := path.Points[i-1], path.Points[i]
+		dx, dy := sign(b.X-a.X), sign(b.Y-a.Y)
+		if abs(b.X-a.X) != abs(b.Y-a.Y) && dx != 0 && dy != 0 {
+			t.Fatalf("segment %v -> %v is neither straight nor diagonal", a, b)
+		}
+		for p := a; p != b; p = (Point{X: p.X + dx, Y: p.Y + dy}) {
+			if !g.CanMove(movement, p.X, p.Y, dx, dy) {
+				t.Fatalf("illegal step from %v by %d,%d in %v", p, dx, dy, path.Points)
+			}
+		}
+	}
+}
+
+func sign(n int) int {
+	switch {
+	case n > 0:
+		return 1
+	case n < 0:
+		return -1
+	}
+	return 0
+}
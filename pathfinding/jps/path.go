@@ -0,0 +1,61 @@
+package jps
+
+import (
+	"errors"
+	"fmt"
+	"pathfinding/grid"
+)
+
+// 导航路径
+type Path struct {
+	// 从起点到终点依次经过的坐标
+	Points []Point
+	// 总移动成本
+	Cost int
+	// 搜索过程中扩展（关闭）的节点数量
+	Expanded int
+	// 预算用完时的部分路径，终点为已扩展节点中离终点最近的节点
+	Partial bool
+}
+
+var (
+	ErrOutOfBounds  = grid.ErrOutOfBounds
+	ErrStartBlocked = errors.New("start point is blocked")
+	ErrEndBlocked   = errors.New("end point is blocked")
+	ErrNoPath       = errors.New("no path found")
+	ErrInvalidMap   = grid.ErrInvalidMap
+	ErrBudget       = errors.New("search budget exhausted")
+)
+
+// 沿父节点回溯生成路径
+func newPath(node *Node, expanded int) *Path {
+	path := &Path{
+		Cost:     node.G,
+		Expanded: expanded,
+	}
+	for ; node != nil; node = node.Parent {
+		path.Points = append(path.Points, Point{X: node.X, Y: node.Y})
+	}
+	// 回溯得到的是终点到起点，反转
+	for i, j := 0, len(path.Points)-1; i < j; i, j = i+1, j-1 {
+		path.Points[i], path.Points[j] = path.Points[j], path.Points[i]
+	}
+	return path
+}
+
+// 检查起止点
+func checkEndpoints(g *Grid, start, end *Node) error {
+	if !g.InBounds(start.X, start.Y) {
+		return fmt.Errorf("start %d,%d: %w", start.X, start.Y, ErrOutOfBounds)
+	}
+	if !g.InBounds(end.X, end.Y) {
+		return fmt.Errorf("end %d,%d: %w", end.X, end.Y, ErrOutOfBounds)
+	}
+	if !g.IsWalkable(start.X, start.Y) {
+		return fmt.Errorf("start %d,%d: %w", start.X, start.Y, ErrStartBlocked)
+	}
+	if !g.IsWalkable(end.X, end.Y) {
+		return fmt.Errorf("end %d,%d: %w", end.X, end.Y, ErrEndBlocked)
+	}
+	return nil
+}
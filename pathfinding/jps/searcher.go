@@ -0,0 +1,66 @@
+package jps
+
+// 寻路器
+// 保存单次搜索的全部状态（节点成本、开放、关闭列表），
+// 同一时间只能被一个goroutine使用，通过Jps.Acquire从池中获取
+type Searcher struct {
+	jps *Jps
+	// 搜索节点，按x*Rows+y存放
+	nodes []Node
+	start *Node
+	end   *Node
+	// 开放、关闭列表
+	openList  []*Node
+	closeList []*Node
+	// 当前搜索批次，每次FindPath递增
+	search int
+	// 本次搜索使用的跳点缓存，为空时逐格跳跃
+	cache *jumpCache
+}
+
+func (r *Jps) NewSearcher() *Searcher {
+	return &Searcher{
+		jps:   r,
+		nodes: make([]Node, r.grid.Rows*r.grid.Cols),
+	}
+}
+
+// 从池中取出一个寻路器，用完后需要Release
+// Init更换地图后，池中寻路器的节点数与新地图不符时重新分配
+func (r *Jps) Acquire() *Searcher {
+	if v := r.pool.Get(); v != nil {
+		s := v.(*Searcher)
+		if size := r.grid.Rows * r.grid.Cols; len(s.nodes) != size {
+			s.nodes = make([]Node, size)
+		}
+		return s
+	}
+	return r.NewSearcher()
+}
+
+// 归还寻路器，归还后不能再访问它返回的节点
+func (r *Jps) Release(s *Searcher) {
+	r.pool.Put(s)
+}
+
+// 开始新一轮搜索
+// 只递增批次并清空列表，节点状态在首次访问时惰性重置
+func (r *Searcher) reset() {
+	r.search++
+	r.openList = r.openList[:0]
+	r.closeList = r.closeList[:0]
+}
+
+// 获取节点，上一轮搜索遗留的状态会被清除
+func (r *Searcher) getNode(x, y int) *Node {
+	node := &r.nodes[x*r.jps.grid.Rows+y]
+	if node.search != r.search {
+		*node = Node{
+			X:      x,
+			Y:      y,
+			Type:   r.jps.grid.TypeAt(x, y),
+			search: r.search,
+		}
+	}
+	return node
+}
@@ -0,0 +1,41 @@
+package jps
+
+import (
+	"pathfinding/grid"
+	"pathfinding/smooth"
+)
+
+// 路径平滑的选项，见smooth包
+type SmoothOptions = smooth.Options
+
+const (
+	LINE_OF_SIGHT_BRESENHAM  = smooth.LINE_OF_SIGHT_BRESENHAM
+	LINE_OF_SIGHT_SUPERCOVER = smooth.LINE_OF_SIGHT_SUPERCOVER
+)
+
+// 把跳点路径展开为逐格的路径
+func Expand(path *Path) *Path {
+	return &Path{
+		Points:   smooth.Expand(path.Points),
+		Cost:     path.Cost,
+		Expanded: path.Expanded,
+		Partial:  path.Partial,
+	}
+}
+
+// 展开跳点后平滑路径，返回新的路径，原路径不变
+// 按与搜索相同的斜向移动规则检查视线，不计地形倍率
+func (r *Jps) Smooth(path *Path, opts SmoothOptions) *Path {
+	movement := grid.MOVEMENT_EIGHT
+	if r.NoCornerCut {
+		movement = grid.MOVEMENT_EIGHT_NO_OBSTACLE
+	}
+	opts.IgnoreRates = true
+	points, cost := smooth.Path(r.grid, movement, smooth.Expand(path.Points), opts)
+	return &Path{
+		Points:   points,
+		Cost:     cost,
+		Expanded: path.Expanded,
+		Partial:  path.Partial,
+	}
+}
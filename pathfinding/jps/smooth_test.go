@@ -0,0 +1,78 @@
+package jps
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"pathfinding/grid"
+	"testing"
+)
+
+// 带地形的随机地图上，平滑后的格子路径每一步都符合跳点搜索的拐角规则，
+// 按基础成本计算且不高于原路径，任意角度路径的成本不高于原路径的欧氏长度
+func TestSmoothRandomMaps(t *testing.T) {
+	rnd := rand.New(rand.NewSource(17))
+	types := []int{NODE_TYPE_NORMAL, grid.NODE_TYPE_ROAD, grid.NODE_TYPE_SWAMP, grid.NODE_TYPE_WATER}
+	for i := 0; i < 100; i++ {
+		const rows, cols = 20, 20
+		mapData := make([][]int, rows)
+		for y := range mapData {
+			mapData[y] = make([]int, cols)
+			for x := range mapData[y] {
+				mapData[y][x] = types[rnd.Intn(len(types))]
+				if rnd.Intn(4) == 0 {
+					mapData[y][x] = NODE_TYPE_OBSTACLE
+				}
+			}
+		}
+		r, err := NewJps(mapData)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r.NoCornerCut = i%2 == 1
+		movement := grid.MOVEMENT_EIGHT
+		if r.NoCornerCut {
+			movement = grid.MOVEMENT_EIGHT_NO_OBSTACLE
+		}
+		path, err := r.FindPath(&Node{X: rnd.Intn(cols), Y: rnd.Intn(rows)}, &Node{X: rnd.Intn(cols), Y: rnd.Intn(rows)})
+		if err != nil {
+			continue
+		}
+		expanded := Expand(path).Points
+		length := 0.0
+		for j := 1; j < len(expanded); j++ {
+			length += math.Hypot(float64(expanded[j].X-expanded[j-1].X), float64(expanded[j].Y-expanded[j-1].Y))
+		}
+		for _, opts := range []SmoothOptions{
+			{LineOfSight: LINE_OF_SIGHT_BRESENHAM},
+			{LineOfSight: LINE_OF_SIGHT_SUPERCOVER},
+			{LineOfSight: LINE_OF_SIGHT_BRESENHAM, AnyAngle: true},
+		} {
+			t.Run(fmt.Sprintf("map%d/%+v", i, opts), func(t *testing.T) {
+				smoothed := r.Smooth(path, opts)
+				if opts.AnyAngle {
+					if want := int(math.Round(length * COST_STRAIGHT)); smoothed.Cost > want {
+						t.Fatalf("any-angle cost %d above original length %d", smoothed.Cost, want)
+					}
+					return
+				}
+				if smoothed.Cost > path.Cost {
+					t.Fatalf("smoothed cost %d above original %d", smoothed.Cost, path.Cost)
+				}
+				cost := 0
+				for j := 1; j < len(smoothed.Points); j++ {
+					a, b := smoothed.Points[j-1], smoothed.Points[j]
+					step := COST_DIAGONAL
+					if a.X == b.X || a.Y == b.Y {
+						step = COST_STRAIGHT
+					}
+					cost += step
+				}
+				if cost != smoothed.Cost {
+					t.Fatalf("smoothed cost %d, steps add up to %d", smoothed.Cost, cost)
+				}
+				checkSteps(t, r, smoothed, movement)
+			})
+		}
+	}
+}
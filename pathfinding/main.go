@@ -0,0 +1,128 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"pathfinding/astar"
+	"pathfinding/jps"
+	"time"
+)
+
+func main() {
+	algo := flag.String("algo", "astar", "示例使用的算法：astar或jps")
+	mapFile := flag.String("map", "", "地图文件：ASCII、CSV或Moving AI .map，为空时使用场景中记录的地图")
+	scenFile := flag.String("scen", "", "Moving AI .scen场景文件，指定后用A*和跳点搜索分别执行每个查询")
+	asJSON := flag.Bool("json", false, "场景测试结果以JSON输出")
+	svgFile := flag.String("svg", "", "把A*示例的搜索过程画成SVG")
+	pngFile := flag.String("png", "", "把A*示例的搜索过程画成PNG")
+	traceFile := flag.String("trace", "", "把A*示例的每一步扩展按JSON行写入文件")
+	flag.Parse()
+	if *scenFile != "" {
+		if err := runScenarios(os.Stdout, *mapFile, *scenFile, *asJSON); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		return
+	}
+	if *algo == "jps" {
+		jpsExample()
+		return
+	}
+	astarExample(*svgFile, *pngFile, *traceFile)
+}
+
+func astarExample(svgFile, pngFile, traceFile string) {
+	// 5x8地图
+	// 0是可移动的网格
+	// 1是障碍网格
+	mapData := [][]int{
+		0: {0, 0, 1, 1, 0, 0, 0, 0},
+		1: {0, 0, 0, 0, 1, 0, 0, 0},
+		2: {0, 0, 0, 1, 1, 0, 0, 0},
+		3: {0, 0, 0, 0, 1, 0, 0, 0},
+		4: {0, 0, 0, 0, 0, 0, 0, 0},
+	}
+	r, err := astar.NewAStar(mapData, nil)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	r.Movement = astar.MOVEMENT_EIGHT_NO_CORNER_CUT
+	searcher := r.Acquire()
+	defer r.Release(searcher)
+	if traceFile != "" {
+		f, err := os.Create(traceFile)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		defer f.Close()
+		searcher.Trace(f)
+	}
+	fmt.Println("开始时间", time.Now().UnixNano())
+	path, err := searcher.FindPath(
+		&astar.Node{X: 0, Y: 0},
+		&astar.Node{X: 5, Y: 0},
+	)
+	fmt.Println("结束时间", time.Now().UnixNano())
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	searcher.Print(path, mapData)
+	if svgFile != "" {
+		if err := writeFile(svgFile, func(w io.Writer) error { return searcher.WriteSVG(w, path) }); err != nil {
+			fmt.Println(err)
+		}
+	}
+	if pngFile != "" {
+		if err := writeFile(pngFile, func(w io.Writer) error { return searcher.WritePNG(w, path) }); err != nil {
+			fmt.Println(err)
+		}
+	}
+}
+
+func jpsExample() {
+	// 5x8地图
+	// 0是可移动的网格
+	// 1是障碍网格
+	mapData := [][]int{
+		{0, 0, 0, 0, 1, 0, 0, 0},
+		{0, 0, 0, 0, 0, 1, 0, 0},
+		{0, 0, 0, 0, 0, 1, 0, 0},
+		{0, 0, 0, 0, 0, 0, 0, 0},
+		{0, 0, 0, 0, 0, 0, 0, 0},
+	}
+	r, err := jps.NewJps(mapData)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	searcher := r.Acquire()
+	defer r.Release(searcher)
+	fmt.Println("开始时间", time.Now().UnixNano())
+	path, err := searcher.FindPath(
+		&jps.Node{X: 0, Y: 0},
+		&jps.Node{X: 6, Y: 2},
+	)
+	fmt.Println("结束时间", time.Now().UnixNano())
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	searcher.Print(path, mapData)
+}
+
+func writeFile(name string, write func(w io.Writer) error) error {
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	if err := write(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,230 @@
+package smooth
+
+import (
+	"math"
+	"pathfinding/grid"
+)
+
+/*
+路径平滑（拉绳）
+从锚点出发沿路径向前，只要锚点能直线到达下一个点且成本不高于原路径，就跳过中间的点，
+否则把上一个点作为新的锚点
+视线检查：
+  Bresenham：沿Bresenham直线逐格移动，每一步都符合移动方式，结果仍是格子路径
+  Supercover：直线经过（包括擦过拐角）的格子都可行，比Bresenham更保守
+任意角度模式只保留拐点，成本与Theta*相同，按直线的欧氏长度计算，
+对角一步约14.14而不是COST_DIAGONAL（14），与格子路径的成本不能直接比较
+A*和跳点搜索的路径都可以平滑，跳点路径先用Expand展开为逐格路径，
+跳点搜索不计地形倍率，平滑时也按基础成本计算
+*/
+
+type Point = grid.Point
+
+// 视线检查方式
+const (
+	LINE_OF_SIGHT_BRESENHAM = iota
+	LINE_OF_SIGHT_SUPERCOVER
+)
+
+type Options struct {
+	// 视线检查方式
+	LineOfSight int
+	// 为true时只返回拐点（任意角度路径），否则把拐点之间的直线展开为格子
+	AnyAngle bool
+	// 为true时不计地形倍率，每个可行格子都按COST_RATE_NORMAL计算
+	IgnoreRates bool
+}
+
+// 把跳点路径展开为逐格的路径，相邻跳点之间是水平、垂直或对角直线
+func Expand(points []Point) []Point {
+	expanded := make([]Point, 0, len(points))
+	for i, p := range points {
+		if i == 0 {
+			expanded = append(expanded, p)
+			continue
+		}
+		// 横纵距离不等时先走对角，再走直线
+		for prev := points[i-1]; prev != p; {
+			prev.X += sign(p.X - prev.X)
+			prev.Y += sign(p.Y - prev.Y)
+			expanded = append(expanded, prev)
+		}
+	}
+	return expanded
+}
+
+// 按移动方式平滑路径，返回新的路径和成本，原路径不变
+// 路径中相邻的点应是相邻的格子，跳点路径先用Expand展开
+func Path(g *grid.Grid, movement int, points []Point, opts Options) ([]Point, int) {
+	g.RLock()
+	defer g.RUnlock()
+	if len(points) < 3 {
+		total := 0.0
+		if len(points) == 2 {
+			total = LineCost(g, points, opts)
+		}
+		return append([]Point(nil), points...), int(math.Round(total))
+	}
+	// 原路径从起点到每个点的成本
+	costs := make([]float64, len(points))
+	for i := 1; i < len(points); i++ {
+		costs[i] = costs[i-1] + LineCost(g, []Point{points[i-1], points[i]}, opts)
+	}
+	smoothed := []Point{points[0]}
+	total := 0.0
+	// 锚点到上一个点的直线，相邻两点之间就是原路径的一步
+	anchor := 0
+	line := []Point{points[0], points[1]}
+	for i := 2; i <= len(points); i++ {
+		if i < len(points) {
+			next, ok := Sight(g, movement, points[anchor], points[i], opts)
+			if ok && LineCost(g, next, opts) <= costs[i]-costs[anchor] {
+				line = next
+				continue
+			}
+		}
+		// 到达终点或看不到下一个点，上一个点成为新的锚点
+		total += LineCost(g, line, opts)
+		if opts.AnyAngle {
+			smoothed = append(smoothed, line[len(line)-1])
+		} else {
+			smoothed = append(smoothed, line[1:]...)
+		}
+		anchor = i - 1
+		if i < len(points) {
+			line = []Point{points[anchor], points[i]}
+		}
+	}
+	return smoothed, int(math.Round(total))
+}
+
+// 从a能否直线到达b，返回检查过的格子，调用方持有地图的读锁
+// 格子路径模式下总是按Bresenham直线检查每一步，再按需要检查Supercover
+func Sight(g *grid.Grid, movement int, a, b Point, opts Options) ([]Point, bool) {
+	var line []Point
+	if !opts.AnyAngle || opts.LineOfSight == LINE_OF_SIGHT_BRESENHAM {
+		line = Bresenham(a, b)
+		for i := 1; i < len(line); i++ {
+			dx, dy := line[i].X-line[i-1].X, line[i].Y-line[i-1].Y
+			if !g.CanMove(movement, line[i-1].X, line[i-1].Y, dx, dy) {
+				return nil, false
+			}
+		}
+	}
+	if opts.LineOfSight == LINE_OF_SIGHT_SUPERCOVER {
+		for _, p := range Supercover(a, b) {
+			if !g.IsWalkable(p.X, p.Y) {
+				return nil, false
+			}
+		}
+		if opts.AnyAngle {
+			line = Supercover(a, b)
+		}
+	}
+	return line, true
+}
+
+// 沿直线移动的成本，调用方持有地图的读锁
+// 格子路径按每一步的成本累加，任意角度路径按欧氏长度乘以经过格子的最大倍率
+func LineCost(g *grid.Grid, line []Point, opts Options) float64 {
+	if !opts.AnyAngle {
+		cost := 0
+		for i := 1; i < len(line); i++ {
+			a, b := line[i-1], line[i]
+			switch {
+			case !opts.IgnoreRates:
+				cost += g.StepCost(a.X, a.Y, b.X, b.Y)
+			case a.X == b.X || a.Y == b.Y:
+				cost += grid.COST_STRAIGHT
+			default:
+				cost += grid.COST_DIAGONAL
+			}
+		}
+		return float64(cost)
+	}
+	rate := grid.COST_RATE_NORMAL
+	if !opts.IgnoreRates {
+		rate = 0
+		for _, p := range line[1:] {
+			rate = max(rate, g.RateAt(p.X, p.Y))
+		}
+	}
+	a, b := line[0], line[len(line)-1]
+	length := math.Hypot(float64(b.X-a.X), float64(b.Y-a.Y))
+	return length * grid.COST_STRAIGHT * float64(rate) / grid.COST_RATE_NORMAL
+}
+
+// Bresenham直线经过的格子，包含两端，相邻格子之间是一步8方向移动
+func Bresenham(a, b Point) []Point {
+	dx, dy := abs(b.X-a.X), -abs(b.Y-a.Y)
+	sx, sy := 1, 1
+	if b.X < a.X {
+		sx = -1
+	}
+	if b.Y < a.Y {
+		sy = -1
+	}
+	points := make([]Point, 0, max(dx, -dy)+1)
+	p := a
+	e := dx + dy
+	for {
+		points = append(points, p)
+		if p == b {
+			return points
+		}
+		e2 := 2 * e
+		if e2 >= dy {
+			e += dy
+			p.X += sx
+		}
+		if e2 <= dx {
+			e += dx
+			p.Y += sy
+		}
+	}
+}
+
+// 直线经过的所有格子，包含两端
+// 直线恰好穿过拐点时，拐点两侧的格子也算经过
+func Supercover(a, b Point) []Point {
+	nx, ny := abs(b.X-a.X), abs(b.Y-a.Y)
+	sx, sy := 1, 1
+	if b.X < a.X {
+		sx = -1
+	}
+	if b.Y < a.Y {
+		sy = -1
+	}
+	points := make([]Point, 0, nx+ny+1)
+	p := a
+	points = append(points, p)
+	for ix, iy := 0, 0; ix < nx || iy < ny; {
+		// 比较直线到达下一条竖线和下一条横线的先后
+		decision := (1+2*ix)*ny - (1+2*iy)*nx
+		switch {
+		case decision == 0:
+			points = append(points, Point{X: p.X + sx, Y: p.Y}, Point{X: p.X, Y: p.Y + sy})
+			p.X += sx
+			p.Y += sy
+			ix++
+			iy++
+		case decision < 0:
+			p.X += sx
+			ix++
+		default:
+			p.Y += sy
+			iy++
+		}
+		points = append(points, p)
+	}
+	return points
+}
+
+func sign(n int) int {
+	return n / max(abs(n), 1)
+}
+
+func abs(n int) int {
+	y := n >> 63
+	return (n ^ y) - y
+}